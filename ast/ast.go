@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"goparsor/token"
+	"strings"
 )
 
 type Node interface {
@@ -23,6 +24,8 @@ type Expression interface {
 // Program node is the root node of our AST
 type Program struct {
 	Statements []Statement
+	// Only filled in when the parser is asked to keep the comments
+	Comments []*Comment
 }
 
 func (p *Program) TokenLiteral() string {
@@ -88,6 +91,28 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// Comment is not a statement, comments are kept aside in
+// Program.Comments in the order of the source.
+type Comment struct {
+	Token token.Token // COMMENT token
+}
+
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+func (c *Comment) String() string       { return c.Token.Literal }
+
+// BadStatement takes the place of a statement that could not be parsed.
+// From and To span the tokens that were skipped, so that tools can still
+// tell where the broken code is.
+type BadStatement struct {
+	Token token.Token // the first token of the statement
+	From  token.Position
+	To    token.Position
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+
 // We treat expressions like a statement, because this is possible:
 // let a = 5
 // a + 10
@@ -108,6 +133,38 @@ func (es *ExpressionStatement) String() string {
 	return ""
 }
 
+// Statements between braces, e.g. the branches of an if expression
+type BlockStatement struct {
+	Token      token.Token // { token
+	Statements []Statement
+}
+
+func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("{ ")
+	for _, stmt := range bs.Statements {
+		out.WriteString(stmt.String() + " ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// BadExpression takes the place of an expression that could not be
+// parsed, see BadStatement.
+type BadExpression struct {
+	Token token.Token // the first token of the expression
+	From  token.Position
+	To    token.Position
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+
 type Identifier struct {
 	Token token.Token // IDENT token
 	Value string      // Expression used for simplification in case e.g. a = b
@@ -128,6 +185,212 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+type Boolean struct {
+	Token token.Token
+	Value bool
+}
+
+func (b *Boolean) expressionNode()      {}
+func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) String() string       { return b.Token.Literal }
+
+// If is an expression, its value is the value of the branch taken:
+// let max = if (a > b) { a } else { b }
+//
+// An "else if" chain is an Alternative holding just another if.
+type IfExpression struct {
+	Token       token.Token // IF token
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (ie *IfExpression) expressionNode()      {}
+func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("if " + ie.Condition.String() + " ")
+	out.WriteString(ie.Consequence.String())
+
+	if ie.Alternative != nil {
+		out.WriteString(" else " + ie.Alternative.String())
+	}
+
+	return out.String()
+}
+
+type FunctionLiteral struct {
+	Token      token.Token // FUNCTION token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) String() string {
+	params := make([]string, 0, len(fl.Parameters))
+	for _, param := range fl.Parameters {
+		params = append(params, param.String())
+	}
+
+	return fl.TokenLiteral() + "(" + strings.Join(params, ", ") + ") " + fl.Body.String()
+}
+
+// Function is either an identifier, e.g. add(1, 2), or any other
+// expression that evaluates to a function, e.g. fn(x) { x }(1)
+type CallExpression struct {
+	Token     token.Token // ( token
+	Function  Expression
+	Arguments []Expression
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) String() string {
+	args := make([]string, 0, len(ce.Arguments))
+	for _, arg := range ce.Arguments {
+		args = append(args, arg.String())
+	}
+
+	return ce.Function.String() + "(" + strings.Join(args, ", ") + ")"
+}
+
+type ArrayLiteral struct {
+	Token    token.Token // [ token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	elements := make([]string, 0, len(al.Elements))
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+type IndexExpression struct {
+	Token token.Token // [ token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	return "(" + ie.Left.String() + "[" + ie.Index.String() + "])"
+}
+
+// Low and High are nil when left out, e.g. arr[:n] or arr[1:]
+type SliceExpression struct {
+	Token token.Token // [ token
+	Left  Expression
+	Low   Expression
+	High  Expression
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(" + se.Left.String() + "[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// Pairs are kept in the order of the source, any expression can be a key:
+// {"name": "x", 1: true, key: value}
+type HashLiteral struct {
+	Token token.Token // { token
+	Pairs []HashPair
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) String() string {
+	pairs := make([]string, 0, len(hl.Pairs))
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+type StringLiteral struct {
+	Token token.Token
+	Value string // Value with all escape sequences decoded
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string {
+	return `"` + stringEscaper.Replace(sl.Value) + `"`
+}
+
+// Used to print the string values back in the form the lexer accepts.
+var stringEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"${", `\${`,
+	"\n", `\n`,
+	"\t", `\t`,
+	"\r", `\r`,
+)
+
+// Template strings embed expressions in their text: "total: ${a + b}".
+// Parts alternate between text and expressions, always starting and
+// ending with a *StringLiteral, which can be empty.
+type TemplateLiteral struct {
+	Token token.Token // TEMPLATE_HEAD token
+	Parts []Expression
+}
+
+func (tl *TemplateLiteral) expressionNode()      {}
+func (tl *TemplateLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TemplateLiteral) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`"`)
+
+	for i, part := range tl.Parts {
+		if text, ok := part.(*StringLiteral); ok && i%2 == 0 {
+			out.WriteString(stringEscaper.Replace(text.Value))
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	out.WriteString(`"`)
+
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token // prefix token e.g. '!' or '-'
 	Operator string
@@ -152,6 +415,8 @@ type InfixExpression struct {
 	Left     Expression
 	Operator string
 	Right    Expression
+	// Set for the logical && and || operators
+	ShortCircuit bool
 }
 
 func (ie *InfixExpression) expressionNode()      {}
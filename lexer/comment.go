@@ -0,0 +1,48 @@
+package lexer
+
+// The isCommentStart(...) function reports if the current char starts
+// either a line comment "// ..." or a block comment "/* ... */".
+func (l *Lexer) isCommentStart() bool {
+	return l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+// The readComment(...) function reads a whole comment, including its
+// delimiters. A line comment stops before the newline, so that the
+// newline is still treated as whitespace. Block comments can be nested:
+// "/* a /* b */ c */" is one comment.
+func (l *Lexer) readComment() string {
+	position := l.position
+	start := l.currPosition()
+
+	l.readChar()
+
+	if l.ch == '/' {
+		for l.ch != '\n' && !l.atEOF() {
+			l.readChar()
+		}
+
+		return l.slice(position, l.position)
+	}
+
+	// Skip over the opening "/*"
+	l.readChar()
+	depth := 1
+
+	for depth > 0 {
+		switch {
+		case l.atEOF():
+			l.report(start, UnterminatedComment, "unterminated block comment")
+			return l.slice(position, l.position)
+		case l.ch == '/' && l.peekChar() == '*':
+			l.readChar()
+			depth += 1
+		case l.ch == '*' && l.peekChar() == '/':
+			l.readChar()
+			depth -= 1
+		}
+
+		l.readChar()
+	}
+
+	return l.slice(position, l.position)
+}
@@ -0,0 +1,81 @@
+package lexer
+
+import (
+	"goparsor/token"
+	"strings"
+)
+
+// Config describes the dialect of the language the lexer reads. Every
+// lexer keeps its own copy of the config, so lexers of different dialects
+// can be used side by side.
+//
+// Start from DefaultConfig() and adjust it, the zero value is a dialect
+// without any keywords where identifiers are made of letters only.
+type Config struct {
+	// Reserved words and the token types they are lexed as. Words that
+	// are not in the table are lexed as identifiers.
+	Keywords map[string]token.TokenType
+	// When set, digits can be used in identifiers after the first char,
+	// e.g. x1. Otherwise x1 is lexed as IDENT followed by INT.
+	IdentDigits bool
+	// Extra chars that are allowed anywhere in identifiers, e.g. "$?"
+	IdentChars string
+}
+
+// DefaultConfig returns the config used by New(...) when no other
+// config is given.
+func DefaultConfig() Config {
+	return Config{
+		Keywords:    token.Keywords(),
+		IdentDigits: true,
+	}
+}
+
+// AddKeyword reserves a word for the given token type. The token type
+// can be one of the types from the token package or a custom one, e.g.
+// token.NewType("WHILE").
+func (c *Config) AddKeyword(word string, tkType token.TokenType) {
+	keywords := make(map[string]token.TokenType, len(c.Keywords)+1)
+	for k, v := range c.Keywords {
+		keywords[k] = v
+	}
+	keywords[word] = tkType
+
+	// A fresh map is used so that configs copied from each other never
+	// share their keyword tables.
+	c.Keywords = keywords
+}
+
+// RemoveKeyword makes the word a regular identifier again.
+func (c *Config) RemoveKeyword(word string) {
+	keywords := make(map[string]token.TokenType, len(c.Keywords))
+	for k, v := range c.Keywords {
+		if k != word {
+			keywords[k] = v
+		}
+	}
+
+	c.Keywords = keywords
+}
+
+// WithConfig makes the lexer use the given dialect instead of
+// DefaultConfig().
+func WithConfig(config Config) Option {
+	return func(l *Lexer) { l.config = config }
+}
+
+func (l *Lexer) lookupIdent(identifier string) token.TokenType {
+	if typ, ok := l.config.Keywords[identifier]; ok {
+		return typ
+	}
+
+	return token.IDENT
+}
+
+func (l *Lexer) isIdentStart(ch rune) bool {
+	return isLetter(ch) || ch != 0 && strings.ContainsRune(l.config.IdentChars, ch)
+}
+
+func (l *Lexer) isIdentChar(ch rune) bool {
+	return l.isIdentStart(ch) || l.config.IdentDigits && isUnicodeDigit(ch)
+}
@@ -0,0 +1,52 @@
+package lexer
+
+import (
+	"fmt"
+	"goparsor/token"
+)
+
+// DiagnosticCode identifies the kind of problem the lexer ran into, so
+// that tools don't have to match on the messages.
+type DiagnosticCode string
+
+const (
+	UnexpectedChar      DiagnosticCode = "unexpected-char"
+	InvalidUTF8         DiagnosticCode = "invalid-utf8"
+	UnterminatedString  DiagnosticCode = "unterminated-string"
+	InvalidEscape       DiagnosticCode = "invalid-escape"
+	UnterminatedComment DiagnosticCode = "unterminated-comment"
+	ReadError           DiagnosticCode = "read-error"
+)
+
+// Diagnostic describes a problem found in the source code. Apart from
+// read errors, each of them belongs to an ILLEGAL or COMMENT token.
+type Diagnostic struct {
+	Pos     token.Position
+	Code    DiagnosticCode
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// The Diagnostics(...) function returns the problems the lexer ran into
+// so far, in the order they were found.
+func (l *Lexer) Diagnostics() []Diagnostic {
+	return l.diagnostics
+}
+
+func (l *Lexer) report(pos token.Position, code DiagnosticCode, msg string) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{Pos: pos, Code: code, Message: msg})
+}
+
+// The reportIllegalChar(...) function reports that the current char does
+// not start any token. The hint is added to the message if not empty.
+func (l *Lexer) reportIllegalChar(hint string) {
+	msg := fmt.Sprintf("unexpected character %q", l.ch)
+	if hint != "" {
+		msg += ", " + hint
+	}
+
+	l.report(l.currPosition(), UnexpectedChar, msg)
+}
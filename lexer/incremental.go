@@ -0,0 +1,211 @@
+package lexer
+
+import (
+	"errors"
+	"goparsor/token"
+	"unicode/utf8"
+)
+
+// Edit replaces Delete bytes of the source, starting at Offset, with
+// the Insert text. This is what editors send on every keystroke.
+type Edit struct {
+	Offset int
+	Delete int
+	Insert string
+}
+
+// Relexed is the result of Relex(...). Tokens[Start:NewEnd] took the
+// place of the old tokens[Start:OldEnd]. All the other tokens are the
+// same as before, the ones after the change only have their positions
+// moved.
+type Relexed struct {
+	Source string
+	Tokens []token.Token
+
+	Start  int
+	OldEnd int
+	NewEnd int
+
+	// Problems found in the relexed part of the source
+	Diagnostics []Diagnostic
+}
+
+// Relex applies the edit to the source and updates its tokens. Instead of
+// lexing the whole source again, it starts right before the edit and
+// stops as soon as the new tokens line up with the old ones.
+//
+// The tokens have to be the complete output of a lexer created with the
+// same options, EOF included.
+func Relex(source string, tokens []token.Token, edit Edit, opts ...Option) (*Relexed, error) {
+	if edit.Offset < 0 || edit.Delete < 0 || edit.Offset+edit.Delete > len(source) {
+		return nil, errors.New("edit is out of the source range")
+	}
+
+	// Positions are counted in chars, they cannot be moved by an edit
+	// that only replaces a part of one.
+	if !onRuneBoundary(source, edit.Offset) || !onRuneBoundary(source, edit.Offset+edit.Delete) {
+		return nil, errors.New("edit splits a multi-byte char")
+	}
+
+	if len(tokens) == 0 || tokens[len(tokens)-1].Type != token.EOF {
+		return nil, errors.New("token stream must end with EOF")
+	}
+
+	newSource := source[:edit.Offset] + edit.Insert + source[edit.Offset+edit.Delete:]
+
+	// A token right before the edit can change as well, e.g. an
+	// identifier that gets longer. One more token is relexed because
+	// the lexer can look a char past the end of a token, like "1." in
+	// "1.x" which becomes a float once x is replaced with a digit.
+	first := 0
+	for first < len(tokens)-1 && fullEnd(tokens[first]) < edit.Offset {
+		first += 1
+	}
+	if first > 0 {
+		first -= 1
+	}
+
+	l := &Lexer{config: DefaultConfig()}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	// The lexer restarts right after the previous token, not at the start
+	// of tokens[first]. The whitespace and comments skipped in front of
+	// it can be part of the edit too.
+	restart := token.Position{Offset: 0, Line: 1, Column: 1}
+	if first > 0 {
+		prev := tokens[first-1]
+		restart = advance(prev.End, prev.Trailing)
+	}
+	l.resetAt(newSource, restart)
+	// Template strings and the last token are the only state the lexer
+	// keeps between tokens, it is recovered from the old tokens.
+	l.templates = replayTemplates(nil, tokens[:first])
+	oldTemplates := replayTemplates(nil, tokens[:first])
+	for i := first - 1; i >= 0; i-- {
+		if tokens[i].Type != token.COMMENT {
+			l.lastToken(tokens[i].Type)
+			break
+		}
+	}
+
+	editEnd := edit.Offset + len(edit.Insert)
+	delta := len(edit.Insert) - edit.Delete
+
+	oldEditEnd := advance(restart, source[restart.Offset:edit.Offset+edit.Delete])
+	newEditEnd := advance(restart, newSource[restart.Offset:editEnd])
+
+	result := &Relexed{
+		Source: newSource,
+		Tokens: append([]token.Token{}, tokens[:first]...),
+		Start:  first,
+	}
+
+	old := first
+	for {
+		tok := l.NextToken()
+		start := fullStart(tok)
+
+		// Once the lexer returns the same token as before, at the same
+		// place in the part of the source that did not change and with
+		// the same templates open, it will keep returning the same
+		// tokens as before. Comments are skipped, whether a newline
+		// after them ends a statement depends on the token before.
+		if start >= editEnd && tok.Type != token.COMMENT {
+			for old < len(tokens) && fullStart(tokens[old])+delta < start {
+				oldTemplates = nextTemplates(oldTemplates, tokens[old].Type)
+				old += 1
+			}
+
+			if old < len(tokens) && fullStart(tokens[old])+delta == start && sameToken(tok, tokens[old]) &&
+				sameTemplates(nextTemplates(append([]int{}, oldTemplates...), tokens[old].Type), l.templates) {
+				result.OldEnd = old
+				result.NewEnd = len(result.Tokens)
+
+				for _, tok := range tokens[old:] {
+					tok.Start = shift(tok.Start, delta, oldEditEnd, newEditEnd)
+					tok.End = shift(tok.End, delta, oldEditEnd, newEditEnd)
+					result.Tokens = append(result.Tokens, tok)
+				}
+
+				break
+			}
+		}
+
+		result.Tokens = append(result.Tokens, tok)
+
+		if tok.Type == token.EOF {
+			result.OldEnd = len(tokens)
+			result.NewEnd = len(result.Tokens)
+			break
+		}
+	}
+
+	result.Diagnostics = l.Diagnostics()
+
+	return result, nil
+}
+
+// The resetAt(...) function makes the lexer continue from the given
+// position of input, as if it had just lexed everything before it.
+func (l *Lexer) resetAt(input string, pos token.Position) {
+	l.input = input
+	l.readPosition = pos.Offset
+	l.line = pos.Line
+	l.column = pos.Column - 1
+	// Any width but 0, which readChar(...) takes for the end of input
+	l.ch, l.width = 0, 1
+	l.readChar()
+}
+
+// The advance(...) function returns the position right after text,
+// when the text starts at pos.
+func advance(pos token.Position, text string) token.Position {
+	for _, ch := range text {
+		if ch == '\n' {
+			pos.Line += 1
+			pos.Column = 0
+		}
+		pos.Column += 1
+	}
+	pos.Offset += len(text)
+
+	return pos
+}
+
+// The shift(...) function moves a position from after the edit in the
+// old source to the same place in the new source. Only the positions on
+// the line where the edit ended have their column changed.
+func shift(pos token.Position, delta int, oldEditEnd, newEditEnd token.Position) token.Position {
+	if pos.Line == oldEditEnd.Line {
+		pos.Column += newEditEnd.Column - oldEditEnd.Column
+	}
+	pos.Line += newEditEnd.Line - oldEditEnd.Line
+	pos.Offset += delta
+
+	return pos
+}
+
+// The onRuneBoundary(...) function reports if offset i of s is not in
+// the middle of a char. Invalid bytes are chars of their own, just like
+// in the lexer.
+func onRuneBoundary(s string, i int) bool {
+	for j := i - 1; j >= 0 && j > i-utf8.UTFMax; j-- {
+		if utf8.RuneStart(s[j]) {
+			_, width := utf8.DecodeRuneInString(s[j:])
+			return j+width <= i
+		}
+	}
+
+	return true
+}
+
+// Offsets of a token including its trivia
+func fullStart(tok token.Token) int { return tok.Start.Offset - len(tok.Leading) }
+func fullEnd(tok token.Token) int   { return tok.End.Offset + len(tok.Trailing) }
+
+func sameToken(a, b token.Token) bool {
+	return a.Type == b.Type && a.Literal == b.Literal &&
+		a.Leading == b.Leading && a.Trailing == b.Trailing
+}
@@ -1,128 +1,303 @@
 package lexer
 
-import "goparsor/token"
+import (
+	"fmt"
+	"goparsor/token"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Lexer struct {
-	// Source code
+	// Source code. When reading from an io.Reader it only holds the part
+	// of the source that is still needed, starting at offset base.
 	input string
+	base  int
+	// Set for lexers created with NewReader(...), see reader.go
+	reader  io.Reader
+	chunk   []byte
+	mark    int
+	readErr error
 	// Current position in input (points to current char)
 	position int
 	// Current reading position in input (after current char).
 	// It always points to one character after the current char,
-	// so its position +1
+	// so its position + width
 	readPosition int
-	// Current char under examination
-	// The byte type only supports ASCII. Pike uses the rune type to support
-	// all unicode and UTF-8 characters. These unsupported chars can have
-	// a size of multiple bytes and would require special handling.
-	ch byte
+	// Current char under examination. Like Pike we use the rune type,
+	// so the whole of unicode is supported. A UTF-8 encoded char can
+	// take up to 4 bytes, width holds the size of the current one.
+	// The width is 0 only once we reach the end of input.
+	ch    rune
+	width int
+	// Line and column of the current char. Both start at 1, the column
+	// is counted in chars (runes), not bytes.
+	line   int
+	column int
+
+	// Brace depth of the open template strings, see template.go
+	templates []int
+	// Set when the next newline ends a statement, see semicolon.go
+	insertSemi bool
+
+	// The dialect of the language, see Config
+	config Config
+
+	// When set, comments are returned as COMMENT tokens instead of
+	// being skipped like whitespace.
+	scanComments bool
+	// When set, whitespace and comments are kept as token trivia,
+	// see trivia.go
+	scanTrivia bool
+
+	diagnostics []Diagnostic
 }
 
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+// Option changes the default behaviour of the lexer, see New(...).
+type Option func(*Lexer)
+
+// WithComments makes the lexer emit COMMENT tokens, so that tools like
+// formatters can keep the comments around.
+func WithComments() Option {
+	return func(l *Lexer) { l.scanComments = true }
+}
+
+func New(input string, opts ...Option) *Lexer {
+	l := &Lexer{
+		input:  input,
+		line:   1,
+		config: DefaultConfig(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) NextToken() token.Token {
+	if l.scanTrivia {
+		return l.nextTokenWithTrivia()
+	}
+
+	tok := l.nextToken()
+	l.lastToken(tok.Type)
+
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
 
+	for l.isCommentStart() {
+		l.mark = l.position
+		start := l.currPosition()
+		comment := l.readComment()
+
+		if l.scanComments {
+			return token.Token{
+				Type:    token.COMMENT,
+				Literal: comment,
+				Start:   start,
+				End:     l.currPosition(),
+			}
+		}
+
+		l.skipWhitespace()
+	}
+
+	if l.atLineEnd() {
+		return l.autoSemicolon()
+	}
+
+	l.mark = l.position
+	start := l.currPosition()
+
 	switch l.ch {
 	case '=':
 		// In case we encounter "=="
 		if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+			tok.Type = token.EQ
 		} else {
-			tok = newToken(token.ASSIGN, l.ch)
+			tok.Type = token.ASSIGN
 		}
 	case ';':
-		tok = newToken(token.SEMICOLON, l.ch)
+		tok.Type = token.SEMICOLON
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok.Type = token.PLUS
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok.Type = token.MINUS
 	case '/':
-		tok = newToken(token.FSLASH, l.ch)
+		tok.Type = token.FSLASH
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		tok.Type = token.ASTERISK
+	case '%':
+		tok.Type = token.PERCENT
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok.Type = token.GT_EQ
+		} else {
+			tok.Type = token.GT
+		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok.Type = token.LT_EQ
+		} else {
+			tok.Type = token.LT
+		}
+	case '&':
+		// Unlike the other operators, a single '&' or '|' is not valid
+		if l.peekChar() == '&' {
+			l.readChar()
+			tok.Type = token.AND
+		} else {
+			tok.Type = token.ILLEGAL
+			l.reportIllegalChar("did you mean '&&'?")
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			l.readChar()
+			tok.Type = token.OR
+		} else {
+			tok.Type = token.ILLEGAL
+			l.reportIllegalChar("did you mean '||'?")
+		}
 	case '!':
 		if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch)}
+			tok.Type = token.NOT_EQ
 		} else {
-			tok = newToken(token.BANG, l.ch)
+			tok.Type = token.BANG
 		}
 	case '(':
-		tok = newToken(token.LPAREN, l.ch)
+		tok.Type = token.LPAREN
 	case ')':
-		tok = newToken(token.RPAREN, l.ch)
+		tok.Type = token.RPAREN
 	case '{':
-		tok = newToken(token.LBRACE, l.ch)
+		tok.Type = token.LBRACE
+		l.enterBlock()
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		if l.leaveTemplate() {
+			tok.Type = l.readTemplatePart()
+		} else {
+			tok.Type = token.RBRACE
+		}
 	case ',':
-		tok = newToken(token.COMMA, l.ch)
+		tok.Type = token.COMMA
+	case ':':
+		tok.Type = token.COLON
+	case '[':
+		tok.Type = token.LBRACKET
+	case ']':
+		tok.Type = token.RBRACKET
+	case '"':
+		tok.Type = l.readString()
 	case 0:
-		tok.Literal = ""
-		tok.Type = token.EOF
+		if l.atEOF() {
+			tok.Type = token.EOF
+		} else {
+			tok.Type = token.ILLEGAL
+			l.reportIllegalChar("")
+		}
 	default:
-		if isLetter(l.ch) {
+		if l.isInvalidUTF8() {
+			tok.Type = token.ILLEGAL
+			tok.Literal = l.readInvalidUTF8()
+			tok.Start, tok.End = start, l.currPosition()
+			l.report(start, InvalidUTF8, fmt.Sprintf("invalid UTF-8 encoding: %q", tok.Literal))
+			return tok
+		} else if l.isIdentStart(l.ch) {
 			// This literal is used to perform a lookup if this
 			// particular identifier is a keyword or not.
 			tok.Literal = l.readIdentifier()
-			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Type = l.lookupIdent(tok.Literal)
+			tok.Start, tok.End = start, l.currPosition()
 			// Exit early because readIdentifier(...) advances l.position.
 			// We don't want to do this again after switch statement.
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Start, tok.End = start, l.currPosition()
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok.Type = token.ILLEGAL
+			l.reportIllegalChar("")
 		}
 	}
 
 	l.readChar()
 
+	// Literals are slices of the input, not copies, so that lexing a
+	// token does not allocate.
+	tok.Literal = l.slice(start.Offset, l.position)
+	tok.Start, tok.End = start, l.currPosition()
+
 	return tok
 }
 
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
+	// Once we are past the end of input there is nothing left to read.
+	// Returning early keeps the position of EOF stable no matter how
+	// many times NextToken(...) is called.
+	if l.column > 0 && l.width == 0 {
+		return
+	}
+
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+
+	l.position = l.readPosition
+	l.column += 1
+	l.fill()
+
+	if l.readPosition >= l.base+len(l.input) {
 		// The zero is a null in ASCII, which for us means EOF or
 		// that we didn't read anything yet. In this case its the former.
 		l.ch = 0
+		l.width = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		// Invalid UTF-8 is decoded as utf8.RuneError with a width of 1,
+		// so we always make progress.
+		l.ch, l.width = utf8.DecodeRuneInString(l.input[l.readPosition-l.base:])
 	}
 
-	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += l.width
+}
+
+// The atEOF(...) function tells the end of input apart from a null char
+// that is part of the input.
+func (l *Lexer) atEOF() bool {
+	return l.ch == 0 && l.width == 0
+}
+
+// The currPosition(...) function returns the position of the current char.
+func (l *Lexer) currPosition() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.column}
 }
 
 // The peekChar(...) function is similar to readChar(...) but it does not
 // advance the current position. It just returns the next char.
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+func (l *Lexer) peekChar() rune {
+	if l.readPosition >= l.base+len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition-l.base:])
+		return ch
 	}
 }
 
 func (l *Lexer) skipWhitespace() {
 	// We don't care about whitespaces, newlines, carriage returns and tabs.
-	for l.ch == ' ' || l.ch == '\n' || l.ch == '\r' || l.ch == '\t' {
+	// Only a newline that ends a statement is kept, see semicolon.go
+	for l.ch == ' ' || l.ch == '\n' && !l.insertSemi || l.ch == '\r' || l.ch == '\t' {
 		// When encountered with any of these, just move forward.
 		l.readChar()
 	}
@@ -130,37 +305,97 @@ func (l *Lexer) skipWhitespace() {
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for l.isIdentChar(l.ch) {
+		l.readChar()
+	}
+
+	return l.slice(position, l.position)
+}
+
+// The readNumber(...) function reads integers in decimal, hex (0xFF),
+// octal (0o17) and binary (0b1010) form and decimal floats (3.14, 1e-9).
+// Digits can be separated with underscores, e.g. 1_000_000.
+//
+// The lexer does not validate the digits. Any letters and digits glued
+// to the number become part of the literal, so that "0b102" is a single
+// token and the parser can report exactly which digit is wrong.
+func (l *Lexer) readNumber() (token.TokenType, string) {
+	position := l.position
+	tkType := token.INT
+
+	if l.ch == '0' && isBasePrefix(l.peekChar()) {
+		l.readChar()
 		l.readChar()
+	} else {
+		l.readDigits()
+
+		if l.ch == '.' && isDigit(l.peekChar()) {
+			tkType = token.FLOAT
+			l.readChar()
+			l.readDigits()
+		}
+
+		if l.ch == 'e' || l.ch == 'E' {
+			tkType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+		}
 	}
 
-	return l.input[position:l.position]
+	for isLetter(l.ch) || isDigit(l.ch) {
+		l.readChar()
+	}
+
+	return tkType, l.slice(position, l.position)
 }
 
-func (l *Lexer) readNumber() string {
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// The isInvalidUTF8(...) function tells apart a broken byte sequence
+// from a properly encoded U+FFFD replacement char, which is 3 bytes wide.
+func (l *Lexer) isInvalidUTF8() bool {
+	return l.ch == utf8.RuneError && l.width == 1
+}
+
+// The readInvalidUTF8(...) function consumes a whole run of invalid bytes,
+// so that they end up in a single ILLEGAL token.
+func (l *Lexer) readInvalidUTF8() string {
 	position := l.position
 
-	for isDigit(l.ch) {
+	for l.isInvalidUTF8() {
 		l.readChar()
 	}
 
-	return l.input[position:l.position]
+	return l.slice(position, l.position)
 }
 
 /*~*~*~*~*~*~*~*~*~*~*~*~* Helper Functions ~*~*~*~*~*~*~*~*~*~*~*~*~*/
 
-func newToken(tkType token.TokenType, ch byte) token.Token {
-	return token.Token{
-		Type:    tkType,
-		Literal: string(ch),
-	}
+func isLetter(ch rune) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
+
+// Digits from other scripts are allowed in identifiers, but numbers
+// themselves are written with ASCII digits only.
+func isUnicodeDigit(ch rune) bool {
+	return isDigit(ch) || ch >= utf8.RuneSelf && unicode.IsDigit(ch)
+}
+
+func isBasePrefix(ch rune) bool {
+	return ch == 'x' || ch == 'X' || ch == 'o' || ch == 'O' || ch == 'b' || ch == 'B'
 }
 
-func isLetter(ch byte) bool {
-	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || ch >= 'a' && ch <= 'f' || ch >= 'A' && ch <= 'F'
 }
 
-func isDigit(ch byte) bool {
-	// We only support integers
+func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
@@ -1,8 +1,13 @@
 package lexer
 
 import (
+	"errors"
+	"fmt"
 	"goparsor/token"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNextToken(t *testing.T) {
@@ -15,7 +20,7 @@ func TestNextToken(t *testing.T) {
 
     let result = add(five, ten);
 
-    !-/*5;
+    !-/ *5;
     10 > 5 < 6;
 
     if (5 > 10) {
@@ -26,6 +31,9 @@ func TestNextToken(t *testing.T) {
 
     11 == 11;
     15 != 11;
+    1 <= 2 >= 3 % 4;
+    a && b || c;
+    [1, 2][0:1];
     `
 
 	tests := []struct {
@@ -89,12 +97,15 @@ func TestNextToken(t *testing.T) {
 		{token.LBRACE, "{"},
 		{token.RETURN, "return"},
 		{token.TRUE, "true"},
+		{token.SEMICOLON, ""},
 		{token.RBRACE, "}"},
 		{token.ELSE, "else"},
 		{token.LBRACE, "{"},
 		{token.RETURN, "return"},
 		{token.FALSE, "false"},
+		{token.SEMICOLON, ""},
 		{token.RBRACE, "}"},
+		{token.SEMICOLON, ""},
 		{token.INT, "11"},
 		{token.EQ, "=="},
 		{token.INT, "11"},
@@ -103,6 +114,31 @@ func TestNextToken(t *testing.T) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "11"},
 		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.LT_EQ, "<="},
+		{token.INT, "2"},
+		{token.GT_EQ, ">="},
+		{token.INT, "3"},
+		{token.PERCENT, "%"},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 
@@ -122,3 +158,855 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "let x = 10;\n  x == 5\n"
+
+	tests := []struct {
+		expectedType  token.TokenType
+		expectedStart token.Position
+		expectedEnd   token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.SEMICOLON, token.Position{Offset: 10, Line: 1, Column: 11}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{token.IDENT, token.Position{Offset: 14, Line: 2, Column: 3}, token.Position{Offset: 15, Line: 2, Column: 4}},
+		{token.EQ, token.Position{Offset: 16, Line: 2, Column: 5}, token.Position{Offset: 18, Line: 2, Column: 7}},
+		{token.INT, token.Position{Offset: 19, Line: 2, Column: 8}, token.Position{Offset: 20, Line: 2, Column: 9}},
+		{token.SEMICOLON, token.Position{Offset: 20, Line: 2, Column: 9}, token.Position{Offset: 20, Line: 2, Column: 9}},
+		{token.EOF, token.Position{Offset: 21, Line: 3, Column: 1}, token.Position{Offset: 21, Line: 3, Column: 1}},
+		{token.EOF, token.Position{Offset: 21, Line: 3, Column: 1}, token.Position{Offset: 21, Line: 3, Column: 1}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Start != tt.expectedStart {
+			t.Fatalf("tests[%d] - start wrong. expected=%+v, got=%+v",
+				i, tt.expectedStart, tok.Start)
+		}
+
+		if tok.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - end wrong. expected=%+v, got=%+v",
+				i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestNextTokenUnicode(t *testing.T) {
+	input := "let łódź = größe2 + ñ;\nx \xff\xfe ok"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedStart   token.Position
+	}{
+		{token.LET, "let", token.Position{Offset: 0, Line: 1, Column: 1}},
+		{token.IDENT, "łódź", token.Position{Offset: 4, Line: 1, Column: 5}},
+		{token.ASSIGN, "=", token.Position{Offset: 12, Line: 1, Column: 10}},
+		{token.IDENT, "größe2", token.Position{Offset: 14, Line: 1, Column: 12}},
+		{token.PLUS, "+", token.Position{Offset: 23, Line: 1, Column: 19}},
+		{token.IDENT, "ñ", token.Position{Offset: 25, Line: 1, Column: 21}},
+		{token.SEMICOLON, ";", token.Position{Offset: 27, Line: 1, Column: 22}},
+		{token.IDENT, "x", token.Position{Offset: 29, Line: 2, Column: 1}},
+		{token.ILLEGAL, "\xff\xfe", token.Position{Offset: 31, Line: 2, Column: 3}},
+		{token.IDENT, "ok", token.Position{Offset: 34, Line: 2, Column: 6}},
+		{token.SEMICOLON, "", token.Position{Offset: 36, Line: 2, Column: 8}},
+		{token.EOF, "", token.Position{Offset: 36, Line: 2, Column: 8}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Start != tt.expectedStart {
+			t.Fatalf("tests[%d] - start wrong. expected=%+v, got=%+v",
+				i, tt.expectedStart, tok.Start)
+		}
+	}
+
+	if len(l.Diagnostics()) != 1 {
+		t.Fatalf("Expected: 1 lexer error, got: %d (%v)", len(l.Diagnostics()), l.Diagnostics())
+	}
+}
+
+func TestNextTokenInsertsSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"x\ny", []token.TokenType{token.IDENT, token.SEMICOLON, token.IDENT, token.SEMICOLON, token.EOF}},
+		{"x;\n\n", []token.TokenType{token.IDENT, token.SEMICOLON, token.EOF}},
+		{"f(1)\n", []token.TokenType{token.IDENT, token.LPAREN, token.INT, token.RPAREN, token.SEMICOLON, token.EOF}},
+		{"{ true }\n", []token.TokenType{token.LBRACE, token.TRUE, token.RBRACE, token.SEMICOLON, token.EOF}},
+		{"[x]\n", []token.TokenType{token.LBRACKET, token.IDENT, token.RBRACKET, token.SEMICOLON, token.EOF}},
+		{"1.5 // note\n\"s\"", []token.TokenType{token.FLOAT, token.SEMICOLON, token.STRING, token.SEMICOLON, token.EOF}},
+		{"return\nx", []token.TokenType{token.RETURN, token.SEMICOLON, token.IDENT, token.SEMICOLON, token.EOF}},
+		// No semicolon after operators, other keywords and block comments
+		{"a +\nb", []token.TokenType{token.IDENT, token.PLUS, token.IDENT, token.SEMICOLON, token.EOF}},
+		{"let\nx", []token.TokenType{token.LET, token.IDENT, token.SEMICOLON, token.EOF}},
+		{"a /*\n*/ b", []token.TokenType{token.IDENT, token.IDENT, token.SEMICOLON, token.EOF}},
+		// Nor right before the text of a template continues
+		{"\"${a\n}\"\n", []token.TokenType{token.TEMPLATE_HEAD, token.IDENT, token.TEMPLATE_TAIL, token.SEMICOLON, token.EOF}},
+	}
+
+	for i, tt := range tests {
+		for _, opts := range [][]Option{nil, {WithComments()}, {WithTrivia()}} {
+			l := New(tt.input, opts...)
+
+			var got []token.TokenType
+			for {
+				tok := l.NextToken()
+				if tok.Type == token.COMMENT {
+					continue
+				}
+				got = append(got, tok.Type)
+
+				if tok.Type == token.SEMICOLON && tok.Literal == "" && tok.Start != tok.End {
+					t.Fatalf("tests[%d] - inserted semicolon is not empty, got: %+v", i, tok)
+				}
+				if tok.Type == token.SEMICOLON && tok.Literal == "" && tok.AtEOF != (tok.Start.Offset == len(tt.input)) {
+					t.Fatalf("tests[%d] - inserted semicolon has a wrong AtEOF, got: %+v", i, tok)
+				}
+				if tok.Type == token.EOF {
+					break
+				}
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Fatalf("tests[%d] - tokens wrong. expected=%v, got=%v", i, tt.expected, got)
+			}
+		}
+	}
+}
+
+func TestNextTokenStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedErrors  int
+	}{
+		{`"foobar"`, token.STRING, `"foobar"`, 0},
+		{`"foo bar"`, token.STRING, `"foo bar"`, 0},
+		{`""`, token.STRING, `""`, 0},
+		{`"say \"hi\"\n"`, token.STRING, `"say \"hi\"\n"`, 0},
+		{`"a\\"`, token.STRING, `"a\\"`, 0},
+		{`"\u{1F600} \u{e9}"`, token.STRING, `"\u{1F600} \u{e9}"`, 0},
+		{`"zażółć"`, token.STRING, `"zażółć"`, 0},
+		{`"unterminated`, token.ILLEGAL, `"unterminated`, 1},
+		{`"ends with \`, token.ILLEGAL, `"ends with \`, 1},
+		{`"bad \q escape"`, token.ILLEGAL, `"bad \q escape"`, 1},
+		{`"bad \u{zz}" + \u{110000}"`, token.ILLEGAL, `"bad \u{zz}"`, 1},
+		{`"\u{110000} \x"`, token.ILLEGAL, `"\u{110000} \x"`, 2},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if len(l.Diagnostics()) != tt.expectedErrors {
+			t.Fatalf("tests[%d] - expected %d errors, got: %v",
+				i, tt.expectedErrors, l.Diagnostics())
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`""`, ""},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"a\nb\tc\r"`, "a\nb\tc\r"},
+		{`"back\\slash"`, `back\slash`},
+		{`"\u{1F600}\u{41}"`, "😀A"},
+	}
+
+	for i, tt := range tests {
+		actual, err := Unquote(tt.literal)
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+
+		if actual != tt.expected {
+			t.Errorf("tests[%d] - expected=%q, got=%q", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestNextTokenComments(t *testing.T) {
+	input := `// leading note
+let x = 5; // trailing note
+/* block /* nested */ still comment */ x / 2;
+/* never closed`
+
+	type expected struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}
+
+	skipped := []expected{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.FSLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	emitted := []expected{
+		{token.COMMENT, "// leading note"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing note"},
+		{token.COMMENT, "/* block /* nested */ still comment */"},
+		{token.IDENT, "x"},
+		{token.FSLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "/* never closed"},
+		{token.EOF, ""},
+	}
+
+	for name, tc := range map[string]struct {
+		lexer *Lexer
+		tests []expected
+	}{
+		"skipped": {New(input), skipped},
+		"emitted": {New(input, WithComments()), emitted},
+	} {
+		for i, tt := range tc.tests {
+			tok := tc.lexer.NextToken()
+
+			if tok.Type != tt.expectedType {
+				t.Fatalf("%s[%d] - tokentype wrong. expected=%q, got=%q",
+					name, i, tt.expectedType, tok.Type)
+			}
+
+			if tok.Literal != tt.expectedLiteral {
+				t.Fatalf("%s[%d] - literal wrong. expected=%q, got=%q",
+					name, i, tt.expectedLiteral, tok.Literal)
+			}
+		}
+
+		if len(tc.lexer.Diagnostics()) != 1 {
+			t.Fatalf("%s - expected 1 error for the unterminated comment, got: %v",
+				name, tc.lexer.Diagnostics())
+		}
+	}
+}
+
+func TestNextTokenNumbers(t *testing.T) {
+	input := `0 42 1_000_000 0xFF 0Xab_cd 0o17 0b1010 3.14 1e-9 2.5E+3 6e2 0b102 12abc 1.5.`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0"},
+		{token.INT, "42"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0xFF"},
+		{token.INT, "0Xab_cd"},
+		{token.INT, "0o17"},
+		{token.INT, "0b1010"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.FLOAT, "6e2"},
+		{token.INT, "0b102"},
+		{token.INT, "12abc"},
+		{token.FLOAT, "1.5"},
+		{token.ILLEGAL, "."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenWithConfig(t *testing.T) {
+	input := `let $total? = x1 while fn`
+
+	dialect := DefaultConfig()
+	dialect.IdentChars = "$?"
+	dialect.AddKeyword("while", token.NewType("WHILE"))
+	dialect.RemoveKeyword("fn")
+
+	strict := DefaultConfig()
+	strict.IdentDigits = false
+
+	type expected struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}
+
+	tests := []struct {
+		lexer    *Lexer
+		expected []expected
+	}{
+		{
+			New(input, WithConfig(dialect)),
+			[]expected{
+				{token.LET, "let"},
+				{token.IDENT, "$total?"},
+				{token.ASSIGN, "="},
+				{token.IDENT, "x1"},
+				{token.NewType("WHILE"), "while"},
+				{token.IDENT, "fn"},
+				{token.SEMICOLON, ""},
+				{token.EOF, ""},
+			},
+		},
+		{
+			New(input, WithConfig(strict)),
+			[]expected{
+				{token.LET, "let"},
+				{token.ILLEGAL, "$"},
+				{token.IDENT, "total"},
+				{token.ILLEGAL, "?"},
+				{token.ASSIGN, "="},
+				{token.IDENT, "x"},
+				{token.INT, "1"},
+				{token.IDENT, "while"},
+				{token.FUNCTION, "fn"},
+				{token.EOF, ""},
+			},
+		},
+	}
+
+	// Interleave both lexers, so that any shared state would show up
+	for i := 0; i < len(tests[1].expected); i++ {
+		for j, tt := range tests {
+			if i >= len(tt.expected) {
+				continue
+			}
+
+			tok := tt.lexer.NextToken()
+
+			if tok.Type != tt.expected[i].expectedType {
+				t.Fatalf("tests[%d][%d] - tokentype wrong. expected=%q, got=%q",
+					j, i, tt.expected[i].expectedType, tok.Type)
+			}
+
+			if tok.Literal != tt.expected[i].expectedLiteral {
+				t.Fatalf("tests[%d][%d] - literal wrong. expected=%q, got=%q",
+					j, i, tt.expected[i].expectedLiteral, tok.Literal)
+			}
+		}
+	}
+
+	if token.LookupIdent("while") != token.IDENT || token.LookupIdent("fn") != token.FUNCTION {
+		t.Fatalf("dialect configs must not change the default keyword table")
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	input := "let łódź = \"zażółć \\u{1F600}\";\n/* a long\n comment */ 0xFF + 3.14 >= x1 // done\n\xff!"
+
+	long := strings.Repeat(input, 500)
+
+	tests := []struct {
+		name   string
+		source string
+		reader io.Reader
+	}{
+		{"whole", input, strings.NewReader(input)},
+		{"one byte", input, iotest.OneByteReader(strings.NewReader(input))},
+		{"half", long, iotest.HalfReader(strings.NewReader(long))},
+	}
+
+	for _, tt := range tests {
+		expected := New(tt.source, WithComments())
+		l := NewReader(tt.reader, WithComments())
+		name := tt.name
+
+		for i := 0; ; i++ {
+			want := expected.NextToken()
+			got := l.NextToken()
+
+			if got != want {
+				t.Fatalf("%s[%d] - token wrong. expected=%+v, got=%+v", name, i, want, got)
+			}
+
+			if got.Type == token.EOF {
+				break
+			}
+		}
+
+		if l.Err() != nil {
+			t.Fatalf("%s - unexpected error: %s", name, l.Err())
+		}
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("let x = 5;"), iotest.ErrReader(errors.New("broken pipe")))
+
+	l := NewReader(r)
+
+	var tokens []token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	if len(tokens) != 5 {
+		t.Fatalf("Expected: 5 tokens before the error, got: %d", len(tokens))
+	}
+
+	if l.Err() == nil || l.Err().Error() != "broken pipe" {
+		t.Fatalf("Expected: broken pipe error, got: %v", l.Err())
+	}
+
+	if len(l.Diagnostics()) != 1 {
+		t.Fatalf("Expected: 1 lexer error, got: %v", l.Diagnostics())
+	}
+}
+
+func TestNextTokenWithTrivia(t *testing.T) {
+	input := "// header\n\nlet x = 5; // five\n\t/* block\n comment */ x\r\n  + \"a\\\"b\" /* trailing */\n\n"
+
+	tests := []struct {
+		expectedLiteral  string
+		expectedLeading  string
+		expectedTrailing string
+	}{
+		{"let", "// header\n\n", " "},
+		{"x", "", " "},
+		{"=", "", " "},
+		{"5", "", ""},
+		{";", "", " // five\n"},
+		{"x", "\t/* block\n comment */ ", "\r"},
+		{"", "", "\n"},
+		{"+", "  ", " "},
+		{`"a\"b"`, "", " /* trailing */"},
+		{"", "", "\n"},
+		{"", "\n", ""},
+	}
+
+	l := New(input, WithTrivia())
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Leading != tt.expectedLeading {
+			t.Fatalf("tests[%d] - leading trivia wrong. expected=%q, got=%q",
+				i, tt.expectedLeading, tok.Leading)
+		}
+
+		if tok.Trailing != tt.expectedTrailing {
+			t.Fatalf("tests[%d] - trailing trivia wrong. expected=%q, got=%q",
+				i, tt.expectedTrailing, tok.Trailing)
+		}
+	}
+}
+
+func TestTriviaRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"   \n\t ",
+		"let x = 5;",
+		"let a = fn(x, y) {\n  x + y; // sum\n};\n",
+		"/* unterminated",
+		"x /* unterminated",
+		"\"unterminated string\n next line",
+		"\xff\xfe @ ł 0b102 1.5. && || & | \"\\q\"",
+		"// only a comment",
+		"\"a ${ b /* c */ } d ${ {e} }\"\n\"unterminated ${ f } g",
+		"a\n\n\n/* one */ /* two */\n// three\nb /* four */ // five\n",
+	}
+
+	for i, input := range inputs {
+		for _, l := range []*Lexer{
+			New(input, WithTrivia()),
+			NewReader(iotest.OneByteReader(strings.NewReader(input)), WithTrivia(), WithComments()),
+		} {
+			var out strings.Builder
+
+			for {
+				tok := l.NextToken()
+				out.WriteString(tok.Leading + tok.Literal + tok.Trailing)
+
+				if tok.Type == token.EOF {
+					break
+				}
+			}
+
+			if out.String() != input {
+				t.Errorf("inputs[%d] - round trip failed. expected=%q, got=%q",
+					i, input, out.String())
+			}
+		}
+	}
+}
+
+func lexAll(input string, opts ...Option) []token.Token {
+	l := New(input, opts...)
+
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+func TestRelex(t *testing.T) {
+	source := "let total = 10;\nlet łódź = \"a b\"; // note\n/* x */ if (a <= 1.5) { return total }\n"
+
+	tests := []struct {
+		edit           Edit
+		expectedStart  int
+		expectedOldEnd int
+		expectedNewEnd int
+	}{
+		// total -> totals
+		{Edit{Offset: 9, Delete: 0, Insert: "s"}, 0, 2, 2},
+		// 10 -> 1.5e3
+		{Edit{Offset: 12, Delete: 2, Insert: "1.5e3"}, 2, 4, 4},
+		// New line in the middle
+		{Edit{Offset: 16, Delete: 0, Insert: "x + y;\n"}, 4, 5, 9},
+		// Open a string that swallows the rest of the source
+		{Edit{Offset: 12, Delete: 0, Insert: "\""}, 2, 21, 7},
+		// Delete everything, only EOF is left
+		{Edit{Offset: 0, Delete: len(source), Insert: ""}, 0, 21, 0},
+	}
+
+	for _, opts := range [][]Option{nil, {WithTrivia()}, {WithComments()}} {
+		tokens := lexAll(source, opts...)
+
+		for i, tt := range tests {
+			result, err := Relex(source, tokens, tt.edit, opts...)
+			if err != nil {
+				t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+			}
+
+			expected := lexAll(result.Source, opts...)
+
+			if len(result.Tokens) != len(expected) {
+				t.Fatalf("tests[%d] - expected %d tokens, got: %d", i, len(expected), len(result.Tokens))
+			}
+
+			for j := range expected {
+				if result.Tokens[j] != expected[j] {
+					t.Fatalf("tests[%d][%d] - token wrong. expected=%+v, got=%+v",
+						i, j, expected[j], result.Tokens[j])
+				}
+			}
+
+			// Only check the ranges for the default options, the
+			// others have a different number of tokens.
+			if opts != nil {
+				continue
+			}
+
+			if result.Start != tt.expectedStart || result.OldEnd != tt.expectedOldEnd || result.NewEnd != tt.expectedNewEnd {
+				t.Errorf("tests[%d] - range wrong. expected=[%d:%d]->[%d:%d], got=[%d:%d]->[%d:%d]",
+					i, tt.expectedStart, tt.expectedOldEnd, tt.expectedStart, tt.expectedNewEnd,
+					result.Start, result.OldEnd, result.Start, result.NewEnd)
+			}
+		}
+	}
+}
+
+func TestRelexMatchesFullLexing(t *testing.T) {
+	source := "let a = fn(x, y) {\n  x + y; // sum\n};\n/* c /* d */ */ \"s\\\"t\" 0x1F 1.5. && ł é2\n\"a ${ {b} + \"c${d}\" } e\" }\n"
+	inserts := []string{"", "a", "1", ".", "\"", "/*", "*/", "//", "\n", " ", "=", "ł", "${", "{", "}"}
+
+	for _, opts := range [][]Option{nil, {WithTrivia()}, {WithComments()}} {
+		tokens := lexAll(source, opts...)
+
+		for offset := 0; offset <= len(source); offset++ {
+			for deleted := 0; deleted <= 2 && offset+deleted <= len(source); deleted++ {
+				for _, insert := range inserts {
+					edit := Edit{Offset: offset, Delete: deleted, Insert: insert}
+					if !onRuneBoundary(source, offset) || !onRuneBoundary(source, offset+deleted) {
+						// Rejected, see TestRelexErrors
+						continue
+					}
+
+					result, err := Relex(source, tokens, edit, opts...)
+					if err != nil {
+						t.Fatalf("%+v - unexpected error: %s", edit, err)
+					}
+
+					expected := lexAll(result.Source, opts...)
+
+					if len(result.Tokens) != len(expected) {
+						t.Fatalf("%+v - expected %d tokens, got: %d", edit, len(expected), len(result.Tokens))
+					}
+
+					for j := range expected {
+						if result.Tokens[j] != expected[j] {
+							t.Fatalf("%+v[%d] - token wrong. expected=%+v, got=%+v",
+								edit, j, expected[j], result.Tokens[j])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRelexInLeadingTrivia(t *testing.T) {
+	tests := []struct {
+		source string
+		edit   Edit
+	}{
+		{"// header\nlet x = 1;\n", Edit{Offset: 0, Delete: 0, Insert: "/"}},
+		{"// header\nlet x = 1;\n", Edit{Offset: 3, Delete: 6, Insert: "y"}},
+		{"// header\nlet x = 1;\n", Edit{Offset: 9, Delete: 1, Insert: ""}},
+		{"  /* a */ x", Edit{Offset: 1, Delete: 0, Insert: "y"}},
+		{"  /* a */ x", Edit{Offset: 6, Delete: 2, Insert: ""}},
+		{"\n", Edit{Offset: 0, Delete: 0, Insert: "\n"}},
+		{"\n", Edit{Offset: 0, Delete: 1, Insert: "let"}},
+		{"/*", Edit{Offset: 2, Delete: 0, Insert: "x"}},
+		{"/* x", Edit{Offset: 4, Delete: 0, Insert: " */ y"}},
+	}
+
+	for _, opts := range [][]Option{nil, {WithTrivia()}, {WithComments()}} {
+		for i, tt := range tests {
+			result, err := Relex(tt.source, lexAll(tt.source, opts...), tt.edit, opts...)
+			if err != nil {
+				t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+			}
+
+			expected := lexAll(result.Source, opts...)
+
+			if len(result.Tokens) != len(expected) {
+				t.Fatalf("tests[%d] - expected %d tokens, got: %d", i, len(expected), len(result.Tokens))
+			}
+
+			for j := range expected {
+				if result.Tokens[j] != expected[j] {
+					t.Fatalf("tests[%d][%d] - token wrong. expected=%+v, got=%+v",
+						i, j, expected[j], result.Tokens[j])
+				}
+			}
+		}
+	}
+}
+
+func TestRelexErrors(t *testing.T) {
+	tokens := lexAll("x")
+
+	if _, err := Relex("x", tokens, Edit{Offset: 1, Delete: 1}); err == nil {
+		t.Errorf("expected an error for an edit out of range")
+	}
+
+	if _, err := Relex("x", tokens[:1], Edit{Offset: 0, Insert: "y"}); err == nil {
+		t.Errorf("expected an error for a stream without EOF")
+	}
+
+	// "é" takes the bytes 1 and 2, "b" after it would get a wrong column
+	source := "aé b"
+	tokens = lexAll(source)
+	for _, edit := range []Edit{{Offset: 2, Insert: "x"}, {Offset: 0, Delete: 2}} {
+		if _, err := Relex(source, tokens, edit); err == nil {
+			t.Errorf("%+v - expected an error for an edit that splits a char", edit)
+		}
+	}
+
+	// Invalid bytes are chars of their own
+	if _, err := Relex("a\x82\x82 b", lexAll("a\x82\x82 b"), Edit{Offset: 2, Delete: 1}); err != nil {
+		t.Errorf("unexpected error for an edit between invalid bytes: %s", err)
+	}
+}
+
+// A script that uses most of the language, repeated to get a realistic size
+var benchmarkScript = strings.Repeat(`
+// Computes the totals of a report
+let total = fn(items, tax) {
+    /* Ignore empty reports */
+    if (len(items) <= 0 || tax < 0.0) {
+        return 0;
+    }
+
+    let sum = reduce(items, fn(acc, x) { acc + x % 1_000 });
+    let name = "zażółć \"gęślą\" jaźń\n";
+    return sum * (1.0 + tax / 100) >= 0xFF && !false;
+};
+`, 100)
+
+func TestNextTokenDoesNotAllocate(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithComments()}, {WithTrivia()}} {
+		l := New(benchmarkScript, opts...)
+
+		allocs := testing.AllocsPerRun(1000, func() {
+			l.NextToken()
+		})
+
+		if allocs != 0 {
+			t.Errorf("Expected: 0 allocations per token, got: %.2f", allocs)
+		}
+	}
+}
+
+func BenchmarkNextToken(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"comments", []Option{WithComments()}},
+		{"trivia", []Option{WithTrivia()}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(benchmarkScript)))
+
+			tokens := 0
+			for i := 0; i < b.N; i++ {
+				l := New(benchmarkScript, bm.opts...)
+				for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+					tokens += 1
+				}
+			}
+
+			b.ReportMetric(float64(tokens)/float64(b.N), "tokens/op")
+		})
+	}
+}
+
+func BenchmarkNewReader(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkScript)))
+
+	for i := 0; i < b.N; i++ {
+		l := NewReader(strings.NewReader(benchmarkScript))
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+	}
+}
+
+func TestNextTokenTemplates(t *testing.T) {
+	input := `"total: ${a + b}!" "${ {x} }${"in ${y}"}" "\${no}" "open ${x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE_HEAD, `"total: ${`},
+		{token.IDENT, "a"},
+		{token.PLUS, "+"},
+		{token.IDENT, "b"},
+		{token.TEMPLATE_TAIL, `}!"`},
+		{token.TEMPLATE_HEAD, `"${`},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.TEMPLATE_MIDDLE, "}${"},
+		{token.TEMPLATE_HEAD, `"in ${`},
+		{token.IDENT, "y"},
+		{token.TEMPLATE_TAIL, `}"`},
+		{token.TEMPLATE_TAIL, `}"`},
+		{token.STRING, `"\${no}"`},
+		{token.TEMPLATE_HEAD, `"open ${`},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTemplateText(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected string
+	}{
+		{`"total: ${`, "total: "},
+		{`} and ${`, " and "},
+		{`}!"`, "!"},
+		{`}"`, ""},
+		{`"a \${b} \"c\" \\${`, `a ${b} "c" \`},
+		{`} unterminated`, " unterminated"},
+	}
+
+	for i, tt := range tests {
+		actual, err := TemplateText(tt.literal)
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+
+		if actual != tt.expected {
+			t.Errorf("tests[%d] - expected=%q, got=%q", i, tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Size of a single read from the underlying io.Reader
+const readChunkSize = 4096
+
+// NewReader creates a lexer that reads the source code from r as it goes,
+// so the source never has to be in memory as a whole. It returns exactly
+// the same tokens as New(...) would for the whole source.
+//
+// A failed read ends the input. The error is reported in Diagnostics(...) and
+// returned by Err(...).
+func NewReader(r io.Reader, opts ...Option) *Lexer {
+	l := &Lexer{
+		reader: r,
+		chunk:  make([]byte, readChunkSize),
+		line:   1,
+		config: DefaultConfig(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.readChar()
+	return l
+}
+
+// Err returns the first error returned by the underlying io.Reader other
+// than io.EOF. It is always nil for lexers created with New(...).
+func (l *Lexer) Err() error {
+	return l.readErr
+}
+
+// The fill(...) function makes sure that the current and the next char
+// can be decoded, by reading more of the source if necessary. Everything
+// before l.mark, the start of the current token, is no longer needed
+// and gets dropped from the buffer.
+func (l *Lexer) fill() {
+	for l.reader != nil && l.base+len(l.input)-l.readPosition < 2*utf8.UTFMax {
+		n, err := l.reader.Read(l.chunk)
+		if n > 0 {
+			l.input = l.input[l.mark-l.base:] + string(l.chunk[:n])
+			l.base = l.mark
+		}
+
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				l.readErr = err
+				l.report(l.currPosition(), ReadError, fmt.Sprintf("read error: %s", err))
+			}
+
+			l.reader = nil
+		}
+	}
+}
+
+// The slice(...) function returns the source between two offsets. Both
+// of them have to be past the start of the current token.
+func (l *Lexer) slice(from, to int) string {
+	return l.input[from-l.base : to-l.base]
+}
@@ -0,0 +1,58 @@
+package lexer
+
+import "goparsor/token"
+
+// Statements can end with a newline instead of a semicolon. Like in Go,
+// the lexer inserts a SEMICOLON when a line ends right after one of the
+// tokens below, so that
+//
+//	let x = 5
+//	x + 1
+//
+// is lexed the same as "let x = 5; x + 1;". The inserted semicolon has an
+// empty literal and sits at the newline, or at the end of input.
+//
+// A newline inside a block comment does not end the line. Neither does
+// a newline right in an embedded expression of a template string, where
+// the expression can only be followed by the rest of the text.
+
+// The endsStatement(...) function reports if a statement can end right
+// after a token of the given type.
+func endsStatement(tkType token.TokenType) bool {
+	switch tkType {
+	case token.IDENT, token.INT, token.FLOAT, token.STRING, token.TEMPLATE_TAIL,
+		token.TRUE, token.FALSE, token.RETURN, token.RPAREN, token.RBRACE, token.RBRACKET:
+		return true
+	default:
+		return false
+	}
+}
+
+// The lastToken(...) function keeps track of the last token that was
+// not a comment, it decides if the next newline ends a statement.
+func (l *Lexer) lastToken(tkType token.TokenType) {
+	if tkType != token.COMMENT {
+		l.insertSemi = endsStatement(tkType) && !l.inTemplateExpression()
+	}
+}
+
+// The inTemplateExpression(...) function reports if the lexer is in an
+// embedded expression of a template, outside of any block.
+func (l *Lexer) inTemplateExpression() bool {
+	return len(l.templates) > 0 && l.templates[len(l.templates)-1] == 0
+}
+
+// The atLineEnd(...) function reports if a semicolon has to be inserted
+// at the current char.
+func (l *Lexer) atLineEnd() bool {
+	return l.insertSemi && (l.ch == '\n' || l.atEOF())
+}
+
+// The autoSemicolon(...) function returns an inserted SEMICOLON at the
+// current char, without consuming it.
+func (l *Lexer) autoSemicolon() token.Token {
+	pos := l.currPosition()
+	l.mark = l.position
+
+	return token.Token{Type: token.SEMICOLON, Start: pos, End: pos, AtEOF: l.atEOF()}
+}
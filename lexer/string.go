@@ -0,0 +1,194 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"goparsor/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// The readString(...) function reads a double-quoted string literal. It
+// is called with the opening quote as the current char and stops at the
+// closing one, just like other multi-char tokens in NextToken(...).
+//
+// Like every other literal, the literal of a string is the source text
+// of the token, quotes included. Escape sequences are only validated
+// here, use Unquote(...) to decode them.
+// Strings that are unterminated or contain a bad escape are returned
+// as ILLEGAL tokens and the problem is reported in the lexer diagnostics.
+//
+// A string that contains "${" is a template, see template.go. Only its
+// text up to and including the "${" is read here.
+func (l *Lexer) readString() token.TokenType {
+	start := l.currPosition()
+
+	end, valid := l.readStringPart()
+
+	switch {
+	case end == partEOF:
+		l.report(start, UnterminatedString, "unterminated string literal")
+		return token.ILLEGAL
+	case end == partExpression:
+		// Even with a bad escape the token keeps its type, the lexer
+		// has to know where the template continues.
+		l.templates = append(l.templates, 0)
+		return token.TEMPLATE_HEAD
+	case !valid:
+		return token.ILLEGAL
+	default:
+		return token.STRING
+	}
+}
+
+// How a part of a string ends
+const (
+	partQuote      = iota // at the closing quote
+	partExpression        // at the "${" that starts an embedded expression
+	partEOF               // at the end of input
+)
+
+// The readStringPart(...) function reads the text of a string up to the
+// closing quote, the start of an embedded expression or the end of input.
+// It reports if all the escape sequences on the way were valid.
+func (l *Lexer) readStringPart() (int, bool) {
+	valid := true
+
+	for {
+		l.readChar()
+
+		switch {
+		case l.ch == '"':
+			return partQuote, valid
+		case l.ch == '$' && l.peekChar() == '{':
+			l.readChar()
+			return partExpression, valid
+		case l.atEOF():
+			return partEOF, valid
+		case l.ch == '\\':
+			escapePos := l.currPosition()
+			l.readChar()
+			// Read the whole escape sequence, so that its quote or
+			// backslash is not mistaken for the end of the string.
+			if l.ch == 'u' && l.peekChar() == '{' {
+				l.readChar()
+				for isHexDigit(l.peekChar()) {
+					l.readChar()
+				}
+				if l.peekChar() == '}' {
+					l.readChar()
+				}
+			}
+
+			// A backslash right before the end of input is reported
+			// as an unterminated string in the next iteration.
+			if l.atEOF() {
+				continue
+			}
+
+			_, _, err := unescapeChar(l.slice(escapePos.Offset, l.readPosition))
+			if err != nil {
+				l.report(escapePos, InvalidEscape, err.Error())
+				valid = false
+			}
+		}
+	}
+}
+
+// Unquote returns the value of a string literal as returned by the lexer
+// in token.Token.Literal, that is without the quotes and with all the
+// escape sequences decoded.
+func Unquote(literal string) (string, error) {
+	if len(literal) < 2 || literal[0] != '"' || literal[len(literal)-1] != '"' {
+		return "", errors.New("string literal must be enclosed in double quotes")
+	}
+
+	return unescape(literal[1 : len(literal)-1])
+}
+
+// The unescape(...) function decodes the escape sequences of the raw
+// text of a string.
+func unescape(raw string) (string, error) {
+	if !strings.ContainsRune(raw, '\\') {
+		return raw, nil
+	}
+
+	var out strings.Builder
+	out.Grow(len(raw))
+
+	for len(raw) > 0 {
+		if raw[0] != '\\' {
+			ch, width := utf8.DecodeRuneInString(raw)
+			out.WriteRune(ch)
+			raw = raw[width:]
+			continue
+		}
+
+		ch, width, err := unescapeChar(raw)
+		if err != nil {
+			return "", err
+		}
+
+		out.WriteRune(ch)
+		raw = raw[width:]
+	}
+
+	return out.String(), nil
+}
+
+// The unescapeChar(...) function decodes a single escape sequence at the
+// start of s. It returns the decoded char and the number of bytes used.
+func unescapeChar(s string) (rune, int, error) {
+	if len(s) < 2 || s[0] != '\\' {
+		return 0, 0, fmt.Errorf("invalid escape sequence: %q", s)
+	}
+
+	switch s[1] {
+	case 'n':
+		return '\n', 2, nil
+	case 't':
+		return '\t', 2, nil
+	case 'r':
+		return '\r', 2, nil
+	case '"':
+		return '"', 2, nil
+	case '$':
+		// Allows writing "\${" without starting a template expression
+		return '$', 2, nil
+	case '\\':
+		return '\\', 2, nil
+	case 'u':
+		end := strings.IndexByte(s, '}')
+		if len(s) < 3 || s[2] != '{' || end < 0 {
+			return 0, 0, errors.New(`invalid unicode escape, expected \u{XXXX}`)
+		}
+
+		digits := s[3:end]
+		if len(digits) == 0 || len(digits) > 6 {
+			return 0, 0, fmt.Errorf("invalid unicode escape: \\u{%s} must have 1 to 6 hex digits", digits)
+		}
+
+		var value rune
+		for _, d := range digits {
+			switch {
+			case '0' <= d && d <= '9':
+				value = value*16 + d - '0'
+			case 'a' <= d && d <= 'f':
+				value = value*16 + d - 'a' + 10
+			case 'A' <= d && d <= 'F':
+				value = value*16 + d - 'A' + 10
+			default:
+				return 0, 0, fmt.Errorf("invalid unicode escape: %q is not a hex digit", d)
+			}
+		}
+
+		if !utf8.ValidRune(value) {
+			return 0, 0, fmt.Errorf("invalid unicode escape: \\u{%s} is not a valid code point", digits)
+		}
+
+		return value, end + 1, nil
+	default:
+		ch, _ := utf8.DecodeRuneInString(s[1:])
+		return 0, 0, fmt.Errorf("unknown escape sequence: \\%c", ch)
+	}
+}
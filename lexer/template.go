@@ -0,0 +1,137 @@
+package lexer
+
+import (
+	"errors"
+	"goparsor/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// Template strings embed expressions in their text:
+//
+//	"total: ${a + b} of ${limit}"
+//
+// is lexed as TEMPLATE_HEAD `"total: ${`, the tokens of a + b,
+// TEMPLATE_MIDDLE `} of ${`, the tokens of limit and TEMPLATE_TAIL `}"`.
+//
+// While lexing an embedded expression, the lexer has to tell the "}"
+// that goes back to the text apart from the ones closing the blocks of
+// the expression itself. For every open template, l.templates holds the
+// number of braces opened in its current expression.
+
+// The readTemplatePart(...) function reads the text of a template that
+// follows an embedded expression. It is called with the closing "}" of
+// the expression as the current char.
+func (l *Lexer) readTemplatePart() token.TokenType {
+	start := l.currPosition()
+
+	end, _ := l.readStringPart()
+
+	switch end {
+	case partExpression:
+		return token.TEMPLATE_MIDDLE
+	case partEOF:
+		l.report(start, UnterminatedString, "unterminated template string")
+	}
+
+	l.templates = l.templates[:len(l.templates)-1]
+	return token.TEMPLATE_TAIL
+}
+
+// The leaveTemplate(...) function reports if the current "}" ends an
+// embedded expression. Otherwise it just closes a block.
+func (l *Lexer) leaveTemplate() bool {
+	if len(l.templates) == 0 {
+		return false
+	}
+
+	top := len(l.templates) - 1
+	if l.templates[top] == 0 {
+		return true
+	}
+
+	l.templates[top] -= 1
+	return false
+}
+
+// The enterBlock(...) function keeps count of the braces opened inside
+// an embedded expression.
+func (l *Lexer) enterBlock() {
+	if len(l.templates) > 0 {
+		l.templates[len(l.templates)-1] += 1
+	}
+}
+
+// TemplateText returns the text of a TEMPLATE_HEAD, TEMPLATE_MIDDLE or
+// TEMPLATE_TAIL literal, without the delimiters and with all the escape
+// sequences decoded.
+func TemplateText(literal string) (string, error) {
+	if !strings.HasPrefix(literal, `"`) && !strings.HasPrefix(literal, "}") {
+		return "", errors.New(`template text must start with '"' or '}'`)
+	}
+
+	var out strings.Builder
+	raw := literal[1:]
+
+	// The text ends at the first quote or "${" that is not escaped. An
+	// unterminated tail has neither, the lexer already reported that.
+	for len(raw) > 0 && raw[0] != '"' && !strings.HasPrefix(raw, "${") {
+		if raw[0] != '\\' {
+			ch, width := utf8.DecodeRuneInString(raw)
+			out.WriteRune(ch)
+			raw = raw[width:]
+			continue
+		}
+
+		ch, width, err := unescapeChar(raw)
+		if err != nil {
+			return "", err
+		}
+
+		out.WriteRune(ch)
+		raw = raw[width:]
+	}
+
+	return out.String(), nil
+}
+
+// The replayTemplates(...) function works out which templates are open
+// after the given tokens, as if the lexer had just returned them.
+func replayTemplates(templates []int, tokens []token.Token) []int {
+	for _, tok := range tokens {
+		templates = nextTemplates(templates, tok.Type)
+	}
+
+	return templates
+}
+
+func nextTemplates(templates []int, tkType token.TokenType) []int {
+	top := len(templates) - 1
+
+	switch {
+	case tkType == token.TEMPLATE_HEAD:
+		templates = append(templates, 0)
+	case tkType == token.TEMPLATE_TAIL && top >= 0:
+		templates = templates[:top]
+	case tkType == token.LBRACE && top >= 0:
+		templates[top] += 1
+	case tkType == token.RBRACE && top >= 0 && templates[top] > 0:
+		templates[top] -= 1
+	}
+
+	return templates
+}
+
+func sameTemplates(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,57 @@
+package lexer
+
+import "goparsor/token"
+
+// WithTrivia makes the lexer keep whitespace and comments as trivia of
+// the tokens, instead of throwing them away. Joining the Leading,
+// Literal and Trailing of all the tokens, EOF included, gives back the
+// input byte for byte.
+//
+// Trailing trivia of a token is everything up to and including the end
+// of its line. The rest, e.g. a comment on its own line, belongs to the
+// leading trivia of the next token. In this mode comments are always
+// trivia, even if WithComments() is used as well.
+func WithTrivia() Option {
+	return func(l *Lexer) { l.scanTrivia = true }
+}
+
+func (l *Lexer) nextTokenWithTrivia() token.Token {
+	leadingStart := l.position
+	l.mark = leadingStart
+	l.skipTrivia(false)
+
+	// Taken before nextToken(...) moves the mark past the trivia
+	leading := l.slice(leadingStart, l.position)
+
+	tok := l.nextToken()
+	l.lastToken(tok.Type)
+
+	l.skipTrivia(true)
+
+	tok.Leading = leading
+	tok.Trailing = l.slice(tok.End.Offset, l.position)
+
+	return tok
+}
+
+// The skipTrivia(...) function skips whitespace and comments. For
+// trailing trivia it stops right after the first newline. It stops
+// right before a newline that ends a statement, the inserted semicolon
+// takes that newline as its trailing trivia.
+func (l *Lexer) skipTrivia(trailing bool) {
+	for {
+		switch {
+		case l.ch == '\n' && !l.insertSemi:
+			l.readChar()
+			if trailing {
+				return
+			}
+		case l.ch == ' ' || l.ch == '\r' || l.ch == '\t':
+			l.readChar()
+		case l.isCommentStart():
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
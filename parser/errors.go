@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"fmt"
+	"goparsor/lexer"
+	"goparsor/token"
+	"sort"
+	"strings"
+)
+
+// ErrorCode identifies the kind of parse error, so that tools don't have
+// to match on the messages. Problems found by the lexer keep the code of
+// their lexer.Diagnostic.
+type ErrorCode string
+
+const (
+	UnexpectedToken    ErrorCode = "unexpected-token"
+	MissingExpression  ErrorCode = "missing-expression"
+	MissingBlock       ErrorCode = "missing-block"
+	UnclosedBlock      ErrorCode = "unclosed-block"
+	InvalidParameter   ErrorCode = "invalid-parameter"
+	DuplicateParameter ErrorCode = "duplicate-parameter"
+	MissingHashColon   ErrorCode = "missing-hash-colon"
+	MissingHashValue   ErrorCode = "missing-hash-value"
+	InvalidNumber      ErrorCode = "invalid-number"
+	InvalidString      ErrorCode = "invalid-string"
+	EmptyTemplateExpr  ErrorCode = "empty-template-expression"
+	MissingSemicolon   ErrorCode = "missing-semicolon"
+)
+
+// Error is a single problem found while parsing. Expected and Found
+// describe the tokens involved for errors about an unexpected token,
+// e.g. "'='" and "number 5", and are empty for the other ones.
+type Error struct {
+	Pos      token.Position
+	Code     ErrorCode
+	Expected string
+	Found    string
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// Snippet returns the line of the source the error is on, with a caret
+// under the column of the error:
+//
+//	3 | let x 5
+//	  |       ^
+func (e *Error) Snippet(source string) string {
+	offset := e.Pos.Offset
+	if offset > len(source) {
+		offset = len(source)
+	}
+
+	start := strings.LastIndexByte(source[:offset], '\n') + 1
+	end := strings.IndexByte(source[offset:], '\n')
+	if end < 0 {
+		end = len(source)
+	} else {
+		end += offset
+	}
+	line := strings.TrimSuffix(source[start:end], "\r")
+
+	// The caret is indented with the same chars as the line, so that
+	// tabs line it up just the same.
+	var indent strings.Builder
+	for _, ch := range source[start:offset] {
+		if ch == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+
+	gutter := fmt.Sprintf("%3d | ", e.Pos.Line)
+	blank := strings.Repeat(" ", len(gutter)-2) + "| "
+
+	return gutter + line + "\n" + blank + indent.String() + "^"
+}
+
+// ErrorList is the list of errors returned by Parser.Errors(). It
+// implements the error interface, so it can be returned as one.
+type ErrorList []*Error
+
+func (l *ErrorList) add(err *Error) {
+	*l = append(*l, err)
+}
+
+func (l ErrorList) Len() int      { return len(l) }
+func (l ErrorList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l ErrorList) Less(i, j int) bool {
+	return l[i].Pos.Offset < l[j].Pos.Offset
+}
+
+// Sort orders the errors by their position in the source. Errors at the
+// same position keep the order they were found in.
+func (l ErrorList) Sort() {
+	sort.Stable(l)
+}
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns the list as an error, or nil if it is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	return l
+}
+
+// Render returns all the errors, each one followed by its snippet of
+// the source.
+func (l ErrorList) Render(source string) string {
+	var out strings.Builder
+
+	for _, err := range l {
+		out.WriteString(err.Error() + "\n")
+		out.WriteString(err.Snippet(source) + "\n")
+	}
+
+	return out.String()
+}
+
+func lexerError(d lexer.Diagnostic) *Error {
+	return &Error{Pos: d.Pos, Code: ErrorCode(d.Code), Message: d.Message}
+}
+
+// The describe(...) function tells what a token is in error messages,
+// e.g. `identifier "x"` rather than just IDENT.
+func describe(tkn token.Token) string {
+	switch {
+	case tkn.Type == token.EOF, tkn.Type == token.SEMICOLON && tkn.AtEOF:
+		return "end of input"
+	case tkn.Type == token.SEMICOLON && tkn.Literal == "":
+		// Inserted by the lexer, see lexer/semicolon.go
+		return "end of line"
+	case tkn.Type == token.IDENT:
+		return fmt.Sprintf("identifier %q", tkn.Literal)
+	case tkn.Type == token.INT || tkn.Type == token.FLOAT:
+		return "number " + tkn.Literal
+	case tkn.Type == token.STRING:
+		return "string " + tkn.Literal
+	case tkn.Type == token.TEMPLATE_HEAD || tkn.Type == token.TEMPLATE_MIDDLE || tkn.Type == token.TEMPLATE_TAIL:
+		return "template string"
+	case tkn.Type == token.ILLEGAL:
+		return fmt.Sprintf("illegal token %q", tkn.Literal)
+	case isWord(tkn.Literal):
+		return "keyword " + tkn.Literal
+	default:
+		return "'" + tkn.Literal + "'"
+	}
+}
+
+// The describeType(...) function tells what token of a type is expected.
+// Operators and delimiters are named after how they are written.
+func describeType(tkType token.TokenType) string {
+	switch tkType {
+	case token.EOF:
+		return "end of input"
+	case token.IDENT:
+		return "identifier"
+	case token.INT, token.FLOAT:
+		return "number"
+	case token.STRING:
+		return "string"
+	}
+
+	for word, keyword := range token.Keywords() {
+		if keyword == tkType {
+			return "keyword " + word
+		}
+	}
+
+	name := tkType.String()
+	if isWord(name) {
+		return strings.ToLower(strings.ReplaceAll(name, "_", " "))
+	}
+
+	return "'" + name + "'"
+}
+
+func isWord(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, ch := range s {
+		if !(ch == '_' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
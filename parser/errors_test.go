@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"goparsor/lexer"
+	"goparsor/token"
+	"testing"
+)
+
+func TestErrorFields(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedCode     ErrorCode
+		expectedExpected string
+		expectedFound    string
+		expectedPos      token.Position
+	}{
+		{"let x 5", UnexpectedToken, "'='", "number 5", token.Position{Offset: 6, Line: 1, Column: 7}},
+		{"let 5", UnexpectedToken, "identifier", "number 5", token.Position{Offset: 4, Line: 1, Column: 5}},
+		{"x +\n", MissingExpression, "expression", "end of input", token.Position{Offset: 4, Line: 2, Column: 1}},
+		{"fn(if) {}", InvalidParameter, "parameter name", "keyword if", token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"0b2", InvalidNumber, "", "", token.Position{Offset: 2, Line: 1, Column: 3}},
+		{"x @", ErrorCode(lexer.UnexpectedChar), "", "", token.Position{Offset: 2, Line: 1, Column: 3}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("Expected errors for %q, got none", tt.input)
+		}
+
+		err := errors[0]
+		if err.Code != tt.expectedCode {
+			t.Errorf("%q - expected code: %s, got: %s", tt.input, tt.expectedCode, err.Code)
+		}
+		if err.Expected != tt.expectedExpected {
+			t.Errorf("%q - expected expected: %s, got: %s", tt.input, tt.expectedExpected, err.Expected)
+		}
+		if err.Found != tt.expectedFound {
+			t.Errorf("%q - expected found: %s, got: %s", tt.input, tt.expectedFound, err.Found)
+		}
+		if err.Pos != tt.expectedPos {
+			t.Errorf("%q - expected position: %+v, got: %+v", tt.input, tt.expectedPos, err.Pos)
+		}
+	}
+}
+
+func TestErrorListIsSortedByPosition(t *testing.T) {
+	// The lexer reads one token ahead, so its error about "@" is found
+	// before the parser gets to the missing "=".
+	input := "let x 5 @"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) < 2 {
+		t.Fatalf("Expected at least 2 errors, got: %d", len(errors))
+	}
+
+	for i := 1; i < len(errors); i++ {
+		if errors[i-1].Pos.Offset > errors[i].Pos.Offset {
+			t.Errorf("Errors not sorted: %q before %q", errors[i-1], errors[i])
+		}
+	}
+
+	var err error = errors
+	if err.Error() != errors[0].Error()+" (and 1 more errors)" {
+		t.Errorf("Unexpected error list message: %q", err.Error())
+	}
+
+	if (ErrorList{}).Err() != nil {
+		t.Errorf("Expected nil error for an empty list")
+	}
+}
+
+func TestErrorSnippet(t *testing.T) {
+	input := "let a = 1;\n\tlet b 2;\n"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	expected := "2:8: expected '=', found number 2\n" +
+		"  2 | \tlet b 2;\n" +
+		"    | \t      ^\n"
+
+	if got := p.Errors().Render(input); got != expected {
+		t.Errorf("Render wrong. expected=\n%s\ngot=\n%s", expected, got)
+	}
+}
@@ -0,0 +1,45 @@
+package parser
+
+import "io"
+
+// Option changes the default behaviour of the parser, see New(...).
+type Option func(*Parser)
+
+// WithErrorLimit makes the parser give up after n errors, the rest are
+// most likely follow-ups. The program it returns is then cut off where it
+// stopped. A limit of 0 or less means no limit, which is the default.
+func WithErrorLimit(n int) Option {
+	return func(p *Parser) { p.errorLimit = n }
+}
+
+// WithAllErrors makes the parser report every error and parse the whole
+// input, no matter how many errors it contains. This is the default, the
+// option undoes an earlier WithErrorLimit(...).
+func WithAllErrors() Option {
+	return func(p *Parser) { p.errorLimit = 0 }
+}
+
+// WithComments makes the parser collect COMMENT tokens in
+// ast.Program.Comments. Lexers only emit them when created with
+// lexer.WithComments().
+func WithComments() Option {
+	return func(p *Parser) { p.parseComments = true }
+}
+
+// WithTrace makes the parser print the parse functions it enters and
+// leaves to w, using a text tracer of its own.
+func WithTrace(w io.Writer) Option {
+	return func(p *Parser) { p.tracer = NewTextTracer(w) }
+}
+
+// WithTracer makes the parser report the parse functions it enters and
+// leaves to the given tracer, see parser_tracing.go
+func WithTracer(tracer Tracer) Option {
+	return func(p *Parser) { p.tracer = tracer }
+}
+
+// WithStrictSemicolons makes the parser report statements that are not
+// ended with a ";", including the ones the lexer ends at a newline.
+func WithStrictSemicolons() Option {
+	return func(p *Parser) { p.strict = true }
+}
@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"bytes"
+	"goparsor/lexer"
+	"strings"
+	"testing"
+)
+
+func TestErrorLimit(t *testing.T) {
+	input := strings.Repeat("let 1;\n", 12)
+
+	tests := []struct {
+		opts           []Option
+		expectedErrors int
+	}{
+		{nil, 12},
+		{[]Option{WithErrorLimit(3)}, 3},
+		{[]Option{WithErrorLimit(3), WithAllErrors()}, 12},
+		{[]Option{WithErrorLimit(0)}, 12},
+	}
+
+	for i, tt := range tests {
+		l := lexer.New(input)
+		p := New(l, tt.opts...)
+		p.ParseProgram()
+
+		if len(p.Errors()) != tt.expectedErrors {
+			t.Errorf("tests[%d] - expected %d errors, got: %d", i, tt.expectedErrors, len(p.Errors()))
+		}
+	}
+}
+
+func TestParsingComments(t *testing.T) {
+	input := "// header\nlet x = 1; /* inline */ x\n"
+
+	l := lexer.New(input, lexer.WithComments())
+	p := New(l, WithComments())
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := []string{"// header", "/* inline */"}
+
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("Expected: %d comments, got: %d", len(expected), len(program.Comments))
+	}
+
+	for i, literal := range expected {
+		if program.Comments[i].String() != literal {
+			t.Errorf("Expected comment: %q, got: %q", literal, program.Comments[i].String())
+		}
+	}
+
+	if len(program.Statements) != 2 {
+		t.Errorf("Expected: 2 statements, got: %d", len(program.Statements))
+	}
+
+	// Without the option, comments are skipped
+	l = lexer.New(input, lexer.WithComments())
+	p = New(l)
+	if program := p.ParseProgram(); len(program.Comments) != 0 {
+		t.Errorf("Expected no comments, got: %d", len(program.Comments))
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var out bytes.Buffer
+
+	l := lexer.New("-x")
+	p := New(l, WithTrace(&out))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "BEGIN ParseProgram (- \"-\", peek IDENT \"x\")\n" +
+		"\tBEGIN parseStatement (- \"-\", peek IDENT \"x\")\n" +
+		"\t\tBEGIN parseExpressionStatement (- \"-\", peek IDENT \"x\")\n" +
+		"\t\t\tBEGIN parseExpression (- \"-\", peek IDENT \"x\")\n" +
+		"\t\t\t\tBEGIN parsePrefixExpression (- \"-\", peek IDENT \"x\")\n" +
+		"\t\t\t\t\tBEGIN parseExpression (IDENT \"x\", peek ; \"\")\n" +
+		"\t\t\t\t\t\tBEGIN parseIdentifier (IDENT \"x\", peek ; \"\")\n" +
+		"\t\t\t\t\t\tEND parseIdentifier (IDENT \"x\", peek ; \"\")\n" +
+		"\t\t\t\t\tEND parseExpression (IDENT \"x\", peek ; \"\")\n" +
+		"\t\t\t\tEND parsePrefixExpression (IDENT \"x\", peek ; \"\")\n" +
+		"\t\t\tEND parseExpression (IDENT \"x\", peek ; \"\")\n" +
+		"\t\tEND parseExpressionStatement (; \"\", peek EOF \"\")\n" +
+		"\tEND parseStatement (; \"\", peek EOF \"\")\n" +
+		"END ParseProgram (EOF \"\", peek EOF \"\")\n"
+
+	if out.String() != expected {
+		t.Errorf("Trace wrong. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestJSONTracer(t *testing.T) {
+	var out bytes.Buffer
+
+	l := lexer.New("x")
+	p := New(l, WithTracer(NewJSONTracer(&out)))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("Expected: 10 events, got: %d\n%s", len(lines), out.String())
+	}
+
+	expected := `{"event":"enter","func":"parseIdentifier","depth":4,` +
+		`"curr":{"type":"IDENT","literal":"x","start":[0,1,1]},` +
+		`"peek":{"type":";","literal":"","start":[1,1,2]}}`
+	if lines[4] != expected {
+		t.Errorf("Event wrong. expected=\n%s\ngot=\n%s", expected, lines[4])
+	}
+
+	expected = `{"event":"exit","func":"ParseProgram","depth":0,` +
+		`"curr":{"type":"EOF","literal":"","start":[1,1,2]},` +
+		`"peek":{"type":"EOF","literal":"","start":[1,1,2]}}`
+	if lines[9] != expected {
+		t.Errorf("Event wrong. expected=\n%s\ngot=\n%s", expected, lines[9])
+	}
+}
+
+func TestTracersOfConcurrentParsers(t *testing.T) {
+	inputs := []string{
+		"let f = fn(x, y) { if (x > y) { x } else { [y][0] } }",
+		"-(1 + 2) * {\"a\": [3, 4]}[\"a\"]",
+	}
+
+	// Each trace must be the same as when the parsers run one by one
+	expected := make([]string, len(inputs))
+	for i, input := range inputs {
+		var out bytes.Buffer
+		New(lexer.New(input), WithTrace(&out)).ParseProgram()
+		expected[i] = out.String()
+	}
+
+	outs := make([]bytes.Buffer, len(inputs))
+	done := make(chan struct{})
+	for i, input := range inputs {
+		go func(i int, input string) {
+			for n := 0; n < 50; n++ {
+				outs[i].Reset()
+				New(lexer.New(input), WithTrace(&outs[i])).ParseProgram()
+			}
+			done <- struct{}{}
+		}(i, input)
+	}
+	for range inputs {
+		<-done
+	}
+
+	for i := range inputs {
+		if outs[i].String() != expected[i] {
+			t.Errorf("%q - trace wrong. expected=\n%s\ngot=\n%s", inputs[i], expected[i], outs[i].String())
+		}
+	}
+}
+
+func TestStrictSemicolons(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{"let x = 1; return x; x + 1;", nil},
+		{"let x = 1\nreturn;", []string{"1:10: expected ';' at the end of the statement, found end of line"}},
+		{"fn() { return 1 };", []string{"1:17: expected ';' at the end of the statement, found '}'"}},
+		{"x", []string{"1:2: expected ';' at the end of the statement, found end of input"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l, WithStrictSemicolons())
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Fatalf("%q - expected %d errors, got: %q", tt.input, len(tt.expectedErrors), errors)
+		}
+
+		for i, msg := range tt.expectedErrors {
+			if errors[i].Error() != msg {
+				t.Errorf("%q - expected error: %s, got: %s", tt.input, msg, errors[i])
+			}
+		}
+
+		// The same input is fine when semicolons are optional
+		if p := New(lexer.New(tt.input)); len(p.ParseProgram().Statements) == 0 || len(p.Errors()) != 0 {
+			t.Errorf("%q - unexpected errors without strict mode: %q", tt.input, p.Errors())
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"goparsor/ast"
 	"goparsor/lexer"
 	"goparsor/token"
 	"strconv"
+	"strings"
 )
 
 ////////////////////////////////////////////////////////////////////
@@ -13,20 +15,44 @@ import (
 ////////////////////////////////////////////////////////////////////
 
 type Parser struct {
-	l      *lexer.Lexer
-	errors []string
+	l      token.Source
+	errors ErrorList
+	// Number of lexer diagnostics already copied over to errors
+	lexerDiagnostics int
 
 	currToken token.Token
 	peekToken token.Token
+	prevToken token.Token
+	// Tokens to read again after a backup(...), see recovery.go
+	pending []token.Token
+
+	// Set after an error, until the parser gets to the next statement
+	panicking bool
+	// Offset of the statement being parsed
+	statementStart int
+	// Number of blocks around the current token
+	blockDepth int
+
+	// Set with options, see options.go
+	errorLimit    int
+	parseComments bool
+	comments      []*ast.Comment
+	tracer        Tracer
+	strict        bool
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
 
-func New(l *lexer.Lexer) *Parser {
+// The parser usually reads from a *lexer.Lexer, but any token.Source
+// will do, e.g. a token stream decoded from a file.
+func New(l token.Source, opts ...Option) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: ErrorList{},
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	p.NextToken()
@@ -35,6 +61,16 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE_HEAD, p.parseTemplateLiteral)
+	p.registerPrefix(token.TRUE, p.parseBoolean)
+	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 
@@ -45,15 +81,47 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.FSLASH, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
 	return p
 }
 
 func (p *Parser) NextToken() {
+	p.prevToken = p.currToken
 	p.currToken = p.peekToken
+
+	if n := len(p.pending); n > 0 {
+		p.peekToken = p.pending[n-1]
+		p.pending = p.pending[:n-1]
+		return
+	}
+
 	p.peekToken = p.l.NextToken()
+
+	// Comments are only emitted by lexers created with
+	// lexer.WithComments(), they never take part in the grammar.
+	for p.peekToken.Type == token.COMMENT {
+		if p.parseComments {
+			p.comments = append(p.comments, &ast.Comment{Token: p.peekToken})
+		}
+		p.peekToken = p.l.NextToken()
+	}
+
+	// The lexer knows best what is wrong with the tokens it could not
+	// make sense of, so its diagnostics are passed through as they are.
+	diagnostics := p.lexerDiagnosticList()
+	for _, d := range diagnostics[p.lexerDiagnostics:] {
+		p.addError(lexerError(d))
+	}
+	p.lexerDiagnostics = len(diagnostics)
 }
 
 /*~*~*~*~*~*~*~*~*~*~*~*~* Pratt Parsing ~*~*~*~*~*~*~*~*~*~*~*~*~*/
@@ -62,23 +130,33 @@ func (p *Parser) NextToken() {
 const (
 	_           int = iota
 	LOWEST          // Default, that we use for comparisons
+	LOGICAL_OR      // ||
+	LOGICAL_AND     // &&
 	EQUALS          // ==
 	LESSGREATER     // > or <
 	SUM             // +
-	PRODUCT         // *
+	PRODUCT         // * or %
 	PREFIX          // -A or !A
 	CALL            // myFunction(A)
+	INDEX           // array[index]
 )
 
 var precedences = map[token.TokenType]int{
+	token.OR:       LOGICAL_OR,
+	token.AND:      LOGICAL_AND,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.LT_EQ:    LESSGREATER,
+	token.GT_EQ:    LESSGREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.ASTERISK: PRODUCT,
 	token.FSLASH:   PRODUCT,
+	token.PERCENT:  PRODUCT,
+	token.LPAREN:   CALL,
+	token.LBRACKET: INDEX,
 }
 
 // Pratt Parsing functions
@@ -116,10 +194,14 @@ func (p *Parser) currPrecedence() int {
 ////////////////////////////////////////////////////////////////////
 
 func (p *Parser) ParseProgram() *ast.Program {
+	defer p.untrace(p.trace("ParseProgram"))
+
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
-	for !p.currTokenIs(token.EOF) {
+	// Past the error limit the parser gives up, what is left of the
+	// input is not parsed.
+	for !p.currTokenIs(token.EOF) && !p.errorLimitReached() {
 		stmt := p.parseStatement()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
@@ -128,21 +210,52 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.NextToken()
 	}
 
+	program.Comments = p.comments
+
 	return program
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	defer p.untrace(p.trace("parseStatement"))
+
+	start := p.currToken
+
+	// Statements can be nested in blocks. The statements of a block are
+	// parsed on their own, no matter if the outer one is broken.
+	outerStart, outerPanicking := p.statementStart, p.panicking
+	p.statementStart, p.panicking = start.Start.Offset, false
+	defer func() { p.statementStart, p.panicking = outerStart, outerPanicking }()
+
+	var stmt ast.Statement
+
 	switch p.currToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if let := p.parseLetStatement(); let != nil {
+			stmt = let
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		stmt = p.parseReturnStatement()
+	case token.SEMICOLON:
+		// An empty statement, e.g. a semicolon on its own line
+		return nil
 	default:
-		return p.parseExpressionStatement()
+		stmt = p.parseExpressionStatement()
+	}
+
+	if p.panicking {
+		p.synchronize()
 	}
+
+	if stmt == nil {
+		return &ast.BadStatement{Token: start, From: start.Start, To: p.currToken.End}
+	}
+
+	return stmt
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
+	defer p.untrace(p.trace("parseLetStatement"))
+
 	// We now that the current token is a statement
 	stmt := &ast.LetStatement{Token: p.currToken}
 
@@ -160,49 +273,84 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		return nil
 	}
 
-	// @TODO: Implement expression handling
-	// We skip the value of an expression for now.
-	for !p.currTokenIs(token.SEMICOLON) {
-		p.NextToken()
-	}
+	p.NextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	p.endStatement()
 
 	return stmt
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
-	stmt := &ast.ReturnStatement{Token: p.currToken}
+	defer p.untrace(p.trace("parseReturnStatement"))
 
-	// In the book there is NextToken() call here, but I don't think
-	// it is necessary in this state.
+	stmt := &ast.ReturnStatement{Token: p.currToken}
 
-	// @TODO: Skipped an expression part until a semicolon is found
-	for !p.currTokenIs(token.SEMICOLON) {
-		p.NextToken()
+	// A bare "return;" has no value, neither has a return that is the
+	// last thing in a block or in the input.
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+		p.endStatement()
+		return stmt
 	}
 
+	p.NextToken()
+
+	stmt.ReturnValue = p.parseExpression(LOWEST)
+
+	p.endStatement()
+
 	return stmt
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	defer p.untrace(p.trace("parseExpressionStatement"))
+
 	stmt := &ast.ExpressionStatement{Token: p.currToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
-	// Semicolon is optional, that's why we either advance
-	// or let it be, without throwing an error
+	p.endStatement()
+
+	return stmt
+}
+
+// The endStatement(...) function consumes the semicolon at the end of a
+// statement. Semicolon is optional, that's why we either advance or let
+// it be, without throwing an error. Unless the parser is strict, then
+// only a ";" written in the source will do.
+func (p *Parser) endStatement() {
 	if p.peekTokenIs(token.SEMICOLON) {
+		// Semicolons inserted by the lexer have no literal
+		if p.strict && p.peekToken.Literal == "" {
+			p.unexpected(p.peekToken, MissingSemicolon, describeType(token.SEMICOLON), " at the end of the statement")
+		}
+
 		p.NextToken()
+		return
 	}
 
-	return stmt
+	if p.strict {
+		p.unexpected(p.peekToken, MissingSemicolon, describeType(token.SEMICOLON), " at the end of the statement")
+	}
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	defer p.untrace(p.trace("parseExpression"))
+
 	// Check if there is an associated prefix parse function
 	prefix := p.prefixParseFns[p.currToken.Type]
 	if prefix == nil {
-		p.noPrefixParseFnError(p.currToken.Type)
-		return nil
+		p.noPrefixParseFnError()
+		bad := p.badExpression(p.currToken)
+
+		// The token that ends an incomplete expression is left for
+		// whatever it ends, unless it is the first one of the statement.
+		if closesConstruct(p.currToken.Type) && p.currToken.Start.Offset != p.statementStart {
+			p.backup()
+		}
+
+		return bad
 	}
 	// If there is such function, call it
 	leftExpr := prefix()
@@ -222,17 +370,44 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
+	defer p.untrace(p.trace("parseIdentifier"))
+
 	return &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseIntegerLiteral"))
+
 	literal := &ast.IntegerLiteral{Token: p.currToken}
 
+	// Unlike in Go, "010" is not an octal number, it would be too easy to
+	// write it by accident. Octal numbers need the "0o" prefix.
+	if hasLeadingZero(p.currToken.Literal) {
+		p.error(p.currToken.Start, InvalidNumber,
+			fmt.Sprintf("invalid leading zero in decimal literal %q, octal literals start with \"0o\"", p.currToken.Literal))
+		return p.badExpression(literal.Token)
+	}
+
 	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("Could not parse: %q as integer", p.currToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		p.numberError(p.currToken, err)
+		return p.badExpression(literal.Token)
+	}
+
+	literal.Value = value
+
+	return literal
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseFloatLiteral"))
+
+	literal := &ast.FloatLiteral{Token: p.currToken}
+
+	value, err := strconv.ParseFloat(p.currToken.Literal, 64)
+	if err != nil {
+		p.numberError(p.currToken, err)
+		return p.badExpression(literal.Token)
 	}
 
 	literal.Value = value
@@ -240,7 +415,395 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return literal
 }
 
+func (p *Parser) parseStringLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseStringLiteral"))
+
+	literal := &ast.StringLiteral{Token: p.currToken}
+
+	// The lexer already reported malformed strings as ILLEGAL tokens,
+	// but the token could also come from somewhere else.
+	value, err := lexer.Unquote(p.currToken.Literal)
+	if err != nil {
+		p.error(p.currToken.Start, InvalidString,
+			fmt.Sprintf("could not parse %q as string: %s", p.currToken.Literal, err))
+		return p.badExpression(literal.Token)
+	}
+
+	literal.Value = value
+
+	return literal
+}
+
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseTemplateLiteral"))
+
+	template := &ast.TemplateLiteral{Token: p.currToken}
+
+	for {
+		text, err := lexer.TemplateText(p.currToken.Literal)
+		if err != nil {
+			if !p.reportedByLexer(p.currToken) {
+				p.error(p.currToken.Start, InvalidString,
+					fmt.Sprintf("could not parse %q as template text: %s", p.currToken.Literal, err))
+			}
+			return p.badExpression(template.Token)
+		}
+
+		template.Parts = append(template.Parts, &ast.StringLiteral{Token: p.currToken, Value: text})
+
+		if p.currTokenIs(token.TEMPLATE_TAIL) {
+			return template
+		}
+
+		if p.peekTokenIs(token.TEMPLATE_MIDDLE) || p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.error(p.peekToken.Start, EmptyTemplateExpr, "empty expression in template string")
+			return p.badExpression(template.Token)
+		}
+
+		p.NextToken()
+
+		expr := p.parseExpression(LOWEST)
+		template.Parts = append(template.Parts, expr)
+
+		if !p.peekTokenIs(token.TEMPLATE_MIDDLE) && !p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.unexpected(p.peekToken, UnexpectedToken, "'}'", " to close the embedded expression")
+			return p.badExpression(template.Token)
+		}
+
+		p.NextToken()
+	}
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	defer p.untrace(p.trace("parseBoolean"))
+
+	return &ast.Boolean{Token: p.currToken, Value: p.currTokenIs(token.TRUE)}
+}
+
+// Parentheses don't have a node of their own. Parsing the inner
+// expression from the lowest precedence is all it takes for them to
+// override the precedence of the operators around.
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	defer p.untrace(p.trace("parseGroupedExpression"))
+
+	start := p.currToken
+	p.NextToken()
+
+	expr := p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return p.badExpression(start)
+	}
+
+	return expr
+}
+
+func (p *Parser) parseIfExpression() ast.Expression {
+	defer p.untrace(p.trace("parseIfExpression"))
+
+	expr := &ast.IfExpression{Token: p.currToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return p.badExpression(expr.Token)
+	}
+
+	p.NextToken()
+	expr.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) || !p.expectBlock("if") {
+		return p.badExpression(expr.Token)
+	}
+
+	expr.Consequence = p.parseBlockStatement()
+
+	if !p.peekTokenIs(token.ELSE) {
+		return expr
+	}
+
+	p.NextToken()
+
+	// In "else if" the nested if becomes the only statement of the
+	// alternative, so chains of any length need nothing special.
+	if p.peekTokenIs(token.IF) {
+		p.NextToken()
+
+		block := &ast.BlockStatement{Token: p.currToken}
+		nested := p.parseIfExpression()
+
+		block.Statements = []ast.Statement{
+			&ast.ExpressionStatement{Token: block.Token, Expression: nested},
+		}
+		expr.Alternative = block
+
+		return expr
+	}
+
+	if !p.expectBlock("else") {
+		return p.badExpression(expr.Token)
+	}
+
+	expr.Alternative = p.parseBlockStatement()
+
+	return expr
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseFunctionLiteral"))
+
+	literal := &ast.FunctionLiteral{Token: p.currToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return p.badExpression(literal.Token)
+	}
+
+	literal.Parameters = p.parseFunctionParameters()
+	if literal.Parameters == nil || !p.expectBlock("fn") {
+		return p.badExpression(literal.Token)
+	}
+
+	literal.Body = p.parseBlockStatement()
+
+	return literal
+}
+
+// The parseFunctionParameters(...) function is called with "(" as the
+// current token and stops at ")". It returns nil if the list is not
+// valid, and an empty slice if there are no parameters.
+func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+	defer p.untrace(p.trace("parseFunctionParameters"))
+
+	params := []*ast.Identifier{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.NextToken()
+		return params
+	}
+
+	seen := make(map[string]bool)
+
+	for {
+		if !p.peekTokenIs(token.IDENT) {
+			p.unexpected(p.peekToken, InvalidParameter, "parameter name", "")
+			return nil
+		}
+
+		p.NextToken()
+
+		param := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		if seen[param.Value] {
+			p.error(param.Token.Start, DuplicateParameter, "duplicate parameter "+param.Value)
+			return nil
+		}
+		seen[param.Value] = true
+		params = append(params, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.NextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return params
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseCallExpression"))
+
+	expr := &ast.CallExpression{Token: p.currToken, Function: function}
+
+	expr.Arguments = p.parseExpressionList(token.RPAREN)
+	if expr.Arguments == nil {
+		return p.badExpression(expr.Token)
+	}
+
+	return expr
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseArrayLiteral"))
+
+	array := &ast.ArrayLiteral{Token: p.currToken}
+
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if array.Elements == nil {
+		return p.badExpression(array.Token)
+	}
+
+	return array
+}
+
+// A "{" only starts a block after if, else and fn. Anywhere else, an
+// expression that starts with "{" is a hash.
+func (p *Parser) parseHashLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseHashLiteral"))
+
+	hash := &ast.HashLiteral{Token: p.currToken}
+	hash.Pairs = []ast.HashPair{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.NextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.peekTokenIs(token.COLON) {
+			p.unexpected(p.peekToken, MissingHashColon, describeType(token.COLON), " after hash key")
+			return p.badHashLiteral(hash.Token)
+		}
+
+		p.NextToken()
+
+		if p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RBRACE) {
+			p.unexpected(p.peekToken, MissingHashValue, "value", " for hash key "+key.String())
+			return p.badHashLiteral(hash.Token)
+		}
+
+		p.NextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		// Just like in lists, the last pair can have a trailing comma
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return p.badHashLiteral(hash.Token)
+		}
+	}
+
+	p.NextToken()
+
+	return hash
+}
+
+// The badHashLiteral(...) function skips the rest of a broken hash
+// literal, up to its "}", which would otherwise be taken for the end of
+// a block.
+func (p *Parser) badHashLiteral(start token.Token) ast.Expression {
+	p.skipToClose(token.LBRACE, token.RBRACE)
+
+	return p.badExpression(start)
+}
+
+// The parseIndexExpression(...) function parses both arr[i] and the
+// slices arr[low:high], where either bound can be left out.
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseIndexExpression"))
+
+	tok := p.currToken
+
+	var low ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.NextToken()
+		low = p.parseExpression(LOWEST)
+	}
+
+	if !p.peekTokenIs(token.COLON) {
+		if !p.expectPeek(token.RBRACKET) {
+			return p.badExpression(tok)
+		}
+
+		return &ast.IndexExpression{Token: tok, Left: left, Index: low}
+	}
+
+	p.NextToken()
+	slice := &ast.SliceExpression{Token: tok, Left: left, Low: low}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.NextToken()
+		slice.High = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return p.badExpression(tok)
+	}
+
+	return slice
+}
+
+// The parseExpressionList(...) function parses comma separated
+// expressions up to the end token, e.g. the arguments of a call. It is
+// called with the opening token as the current token.
+//
+// A trailing comma is allowed, so that a list can be split over lines
+// without a semicolon being inserted after its last element.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	defer p.untrace(p.trace("parseExpressionList"))
+
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.NextToken()
+		return list
+	}
+
+	p.NextToken()
+	list = append(list, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+		p.NextToken()
+		if p.peekTokenIs(end) {
+			break
+		}
+
+		p.NextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return list
+}
+
+// The parseBlockStatement(...) function is called with "{" as the
+// current token and stops at the matching "}".
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	defer p.untrace(p.trace("parseBlockStatement"))
+
+	block := &ast.BlockStatement{Token: p.currToken}
+	block.Statements = []ast.Statement{}
+
+	p.NextToken()
+	p.blockDepth += 1
+
+	for !p.currTokenIs(token.RBRACE) {
+		if p.currTokenIs(token.EOF) {
+			p.unexpected(p.currToken, UnclosedBlock, describeType(token.RBRACE),
+				" to close the block opened at "+block.Token.Start.String())
+			break
+		}
+
+		stmt := p.parseStatement()
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+
+		p.NextToken()
+	}
+
+	p.blockDepth -= 1
+
+	return block
+}
+
+// The expectBlock(...) function works like expectPeek(...) for the
+// opening brace of a block, with an error that tells whose block it is.
+func (p *Parser) expectBlock(owner string) bool {
+	if p.peekTokenIs(token.LBRACE) {
+		p.NextToken()
+		return true
+	}
+
+	p.unexpected(p.peekToken, MissingBlock, describeType(token.LBRACE), " to start the block of "+owner)
+
+	return false
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	defer p.untrace(p.trace("parsePrefixExpression"))
+
 	prefixExpr := &ast.PrefixExpression{
 		Token:    p.currToken,
 		Operator: p.currToken.Literal,
@@ -254,10 +817,15 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseInfixExpression"))
+
 	expr := &ast.InfixExpression{
 		Token:    p.currToken,
 		Left:     left,
 		Operator: p.currToken.Literal,
+		// The right side of && and || is only evaluated if the left
+		// side does not decide the result on its own.
+		ShortCircuit: p.currTokenIs(token.AND) || p.currTokenIs(token.OR),
 	}
 
 	precedence := p.currPrecedence()
@@ -292,17 +860,150 @@ func (p *Parser) expectPeek(tkn token.TokenType) bool {
 	}
 }
 
-func (p *Parser) Errors() []string {
+// Errors returns the errors found so far, sorted by their position.
+func (p *Parser) Errors() ErrorList {
+	p.errors.Sort()
 	return p.errors
 }
 
+// The addError(...) function drops the errors past the error limit.
+func (p *Parser) addError(err *Error) {
+	if !p.errorLimitReached() {
+		p.errors.add(err)
+	}
+}
+
+func (p *Parser) errorLimitReached() bool {
+	return p.errorLimit > 0 && len(p.errors) >= p.errorLimit
+}
+
+func (p *Parser) error(pos token.Position, code ErrorCode, msg string) {
+	if p.panicking {
+		return
+	}
+
+	p.panicking = true
+	p.addError(&Error{Pos: pos, Code: code, Message: msg})
+}
+
+// The unexpected(...) function reports that the token is not the one
+// expected. The context, if any, tells what the expected token is for,
+// e.g. " after hash key".
+func (p *Parser) unexpected(tkn token.Token, code ErrorCode, expected, context string) {
+	// A token the lexer reported is still an error, that is why the
+	// parser panics anyway.
+	if p.panicking || p.reportedByLexer(tkn) {
+		p.panicking = true
+		return
+	}
+
+	p.panicking = true
+
+	found := describe(tkn)
+	p.addError(&Error{
+		Pos:      tkn.Start,
+		Code:     code,
+		Expected: expected,
+		Found:    found,
+		Message:  "expected " + expected + context + ", found " + found,
+	})
+}
+
 func (p *Parser) peekError(tkn token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be: %s, instead got: %s",
-		tkn, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.unexpected(p.peekToken, UnexpectedToken, describeType(tkn), "")
+}
+
+// The numberError(...) function explains why a number literal could not
+// be parsed. The lexer hands over everything that looks like a number,
+// so in most cases we can point at the exact digit that is wrong.
+func (p *Parser) numberError(tkn token.Token, err error) {
+	literal := tkn.Literal
+	kind, base, digits := "decimal", 10, literal
+
+	if tkn.Type == token.FLOAT {
+		kind = "float"
+	} else if len(literal) > 1 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			kind, base, digits = "hexadecimal", 16, literal[2:]
+		case 'o', 'O':
+			kind, base, digits = "octal", 8, literal[2:]
+		case 'b', 'B':
+			kind, base, digits = "binary", 2, literal[2:]
+		}
+	}
+
+	prefix := len(literal) - len(digits)
+	pos := tkn.Start
+	pos.Column += prefix
+
+	for i, ch := range digits {
+		if !isNumberChar(ch, base, tkn.Type == token.FLOAT) {
+			pos.Offset = tkn.Start.Offset + prefix + i
+			p.error(pos, InvalidNumber, fmt.Sprintf("invalid digit %q in %s literal %q", ch, kind, literal))
+			return
+		}
+
+		pos.Column += 1
+	}
+
+	var msg string
+	if errors.Is(err, strconv.ErrRange) {
+		msg = fmt.Sprintf("%s literal %q is out of range", kind, literal)
+	} else {
+		msg = fmt.Sprintf("malformed %s literal %q", kind, literal)
+	}
+	p.error(tkn.Start, InvalidNumber, msg)
+}
+
+func hasLeadingZero(literal string) bool {
+	return len(literal) > 1 && literal[0] == '0' && !strings.ContainsRune("xXoObB", rune(literal[1]))
+}
+
+func isNumberChar(ch rune, base int, isFloat bool) bool {
+	switch {
+	case ch == '_':
+		return true
+	case isFloat:
+		return '0' <= ch && ch <= '9' || strings.ContainsRune(".eE+-", ch)
+	case '0' <= ch && ch <= '9':
+		return int(ch-'0') < base
+	case 'a' <= ch && ch <= 'f':
+		return base == 16
+	case 'A' <= ch && ch <= 'F':
+		return base == 16
+	default:
+		return false
+	}
+}
+
+// Implemented by *lexer.Lexer. Other token sources don't have to
+// report diagnostics.
+type diagnosticSource interface {
+	Diagnostics() []lexer.Diagnostic
+}
+
+func (p *Parser) lexerDiagnosticList() []lexer.Diagnostic {
+	if src, ok := p.l.(diagnosticSource); ok {
+		return src.Diagnostics()
+	}
+
+	return nil
+}
+
+// The reportedByLexer(...) function reports if the lexer has already
+// explained what is wrong with the token, e.g. why it is ILLEGAL. Another
+// error about the same token would only hide the real cause.
+func (p *Parser) reportedByLexer(tkn token.Token) bool {
+	for _, d := range p.lexerDiagnosticList() {
+		if tkn.Start.Offset <= d.Pos.Offset && d.Pos.Offset < tkn.End.Offset {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (p *Parser) noPrefixParseFnError(tkn token.TokenType) {
-	msg := fmt.Sprintf("No prefix parse function found for token: %s", tkn)
-	p.errors = append(p.errors, msg)
+func (p *Parser) noPrefixParseFnError() {
+	p.unexpected(p.currToken, MissingExpression, "expression", "")
 }
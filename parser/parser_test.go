@@ -1,47 +1,49 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"goparsor/ast"
 	"goparsor/lexer"
+	"goparsor/token"
 	"testing"
 )
 
 func TestParsingLetStatements(t *testing.T) {
-	input := `
-    let x = 5;
-    let foo = 123;
-    
-    let bar = 0;
-    `
-
-	l := lexer.New(input)
-	p := New(l)
-
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
-
-	if program == nil {
-		t.Fatalf("ParseProgram(...) returned nil")
-	}
-
-	if len(program.Statements) != 3 {
-		t.Fatalf("expected 3 statements, got %d", len(program.Statements))
-	}
-
 	tests := []struct {
+		input              string
 		expectedIdentifier string
+		expectedValue      interface{}
 	}{
-		{"x"},
-		{"foo"},
-		{"bar"},
+		{"let x = 5;", "x", 5},
+		{"let foo = bar;", "foo", "bar"},
+		{"let y = x + 1", "y", "(x + 1)"},
 	}
 
-	for i, tt := range tests {
-		stmt := program.Statements[i]
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program == nil {
+			t.Fatalf("ParseProgram(...) returned nil")
+		}
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement, got %d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0]
 		if !testLetStatement(t, stmt, tt.expectedIdentifier) {
 			return
 		}
+
+		value := stmt.(*ast.LetStatement).Value
+		if !testLiteralExpression(t, value, tt.expectedValue) {
+			return
+		}
 	}
 }
 
@@ -70,13 +72,63 @@ func testLetStatement(t *testing.T, stmt ast.Statement, identName string) bool {
 }
 
 func TestParsingReturnStatements(t *testing.T) {
-	input := `
-    return 5;
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+	}{
+		{"return 5;", 5},
+		{"return foobar;", "foobar"},
+		{"return 2 * x", "(2 * x)"},
+		// No value at all
+		{"return;", nil},
+		{"return", nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program == nil {
+			t.Fatalf("ParseProgram(...) returned nil")
+		}
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected: 1 return statement, got: %d", len(program.Statements))
+		}
+
+		returnStmt, ok := program.Statements[0].(*ast.ReturnStatement)
+		if !ok {
+			t.Fatalf("Expected statement of type *ast.ReturnStatement, got: %T", program.Statements[0])
+		}
+
+		if returnStmt.TokenLiteral() != "return" {
+			t.Errorf("Return statement expected to have: 'return' literal, got: %q", returnStmt.TokenLiteral())
+		}
+
+		if tt.expectedValue == nil {
+			if returnStmt.ReturnValue != nil {
+				t.Errorf("Expected no return value, got: %q", returnStmt.ReturnValue.String())
+			}
+			continue
+		}
+
+		if !testLiteralExpression(t, returnStmt.ReturnValue, tt.expectedValue) {
+			return
+		}
+	}
+}
 
-    return 0;
-    return 99999999;
+func TestParsingWithoutSemicolons(t *testing.T) {
+	input := `
+    let x = 5
+    return x
 
-    `
+    x + 1
+    ;
+    let y = x`
 
 	l := lexer.New(input)
 	p := New(l)
@@ -84,26 +136,27 @@ func TestParsingReturnStatements(t *testing.T) {
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	if program == nil {
-		t.Fatalf("ParseProgram(...) returned nil")
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got %d: %q", len(program.Statements), program.String())
 	}
 
-	stmts := program.Statements
+	testLetStatement(t, program.Statements[0], "x")
+	testLetStatement(t, program.Statements[3], "y")
 
-	if len(stmts) != 3 {
-		t.Errorf("Expected: 3 return statements, got: %d", len(stmts))
+	if _, ok := program.Statements[1].(*ast.ReturnStatement); !ok {
+		t.Errorf("Expected statement of type *ast.ReturnStatement, got: %T", program.Statements[1])
+	}
+	if _, ok := program.Statements[2].(*ast.ExpressionStatement); !ok {
+		t.Errorf("Expected statement of type *ast.ExpressionStatement, got: %T", program.Statements[2])
 	}
 
-	for _, stmt := range stmts {
-		returnStmt, ok := stmt.(*ast.ReturnStatement)
-		if !ok {
-			t.Errorf("Expected statement of type *ast.ReturnStatement, got: %T", stmt)
-			continue
-		}
+	// Like in Go, a return at the end of a line has no value
+	p = New(lexer.New("return\nx"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
 
-		if returnStmt.TokenLiteral() != "return" {
-			t.Errorf("Return statement expected to have: 'return' literal, got: %q", returnStmt.TokenLiteral())
-		}
+	if program.String() != "return;x" {
+		t.Errorf("Expected: 2 statements \"return;x\", got: %q", program.String())
 	}
 }
 
@@ -183,6 +236,244 @@ func TestParsingIntegerLiterals(t *testing.T) {
 	}
 }
 
+func TestParsingNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"0xFF", int64(255)},
+		{"0o17", int64(15)},
+		{"0b1010", int64(10)},
+		{"1_000_000", int64(1000000)},
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"2_000.5", 2000.5},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected: 1 statement, got: %d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		switch expected := tt.expected.(type) {
+		case int64:
+			literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+			if !ok {
+				t.Fatalf("Expected: *ast.IntegerLiteral, got: %T", stmt.Expression)
+			}
+			if literal.Value != expected {
+				t.Errorf("Expected literal value: %d, got: %d", expected, literal.Value)
+			}
+		case float64:
+			literal, ok := stmt.Expression.(*ast.FloatLiteral)
+			if !ok {
+				t.Fatalf("Expected: *ast.FloatLiteral, got: %T", stmt.Expression)
+			}
+			if literal.Value != expected {
+				t.Errorf("Expected literal value: %g, got: %g", expected, literal.Value)
+			}
+		}
+
+		if stmt.String() != tt.input {
+			t.Errorf("Expected string: %s, got: %s", tt.input, stmt.String())
+		}
+	}
+}
+
+func TestParsingMalformedNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"0b102", `1:5: invalid digit '2' in binary literal "0b102"`},
+		{"0o78", `1:4: invalid digit '8' in octal literal "0o78"`},
+		{"x + 0xFG", `1:8: invalid digit 'G' in hexadecimal literal "0xFG"`},
+		{"12abc", `1:3: invalid digit 'a' in decimal literal "12abc"`},
+		{"1.5x", `1:4: invalid digit 'x' in float literal "1.5x"`},
+		{"1e", `1:1: malformed float literal "1e"`},
+		{"0x", `1:1: malformed hexadecimal literal "0x"`},
+		{"010", `1:1: invalid leading zero in decimal literal "010", octal literals start with "0o"`},
+		{"08", `1:1: invalid leading zero in decimal literal "08", octal literals start with "0o"`},
+		{"99999999999999999999", `1:1: decimal literal "99999999999999999999" is out of range`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("Expected: 1 error for %q, got: %q", tt.input, errors)
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("Expected error: %s, got: %s", tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestLexerDiagnosticsArePassedThrough(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{"x @ 5;", []string{"1:3: unexpected character '@'"}},
+		{"a & b", []string{"1:3: unexpected character '&', did you mean '&&'?"}},
+		{"x + \"oops;", []string{"1:5: unterminated string literal"}},
+		{"let x = 1; /* open", []string{"1:12: unterminated block comment"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Fatalf("Expected: %d errors for %q, got: %q",
+				len(tt.expectedErrors), tt.input, errors)
+		}
+
+		for i, msg := range tt.expectedErrors {
+			if errors[i].Error() != msg {
+				t.Errorf("Expected error: %s, got: %s", msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestParsingFromTokenStream(t *testing.T) {
+	input := `let x = 5; -a * "b" <= 0x10`
+
+	var buf bytes.Buffer
+	if err := token.Copy(token.NewBinaryEncoder(&buf), lexer.New(input)); err != nil {
+		t.Fatalf("could not encode tokens: %s", err)
+	}
+
+	fromLexer := New(lexer.New(input)).ParseProgram()
+
+	dec := token.NewBinaryDecoder(&buf)
+	p := New(dec)
+	fromStream := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if dec.Err() != nil {
+		t.Fatalf("could not decode tokens: %s", dec.Err())
+	}
+
+	if fromStream.String() != fromLexer.String() {
+		t.Errorf("Expected: %s, got: %s", fromLexer.String(), fromStream.String())
+	}
+}
+
+func TestParsingStringLiterals(t *testing.T) {
+	input := `"hello \"world\"\n";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Expected: 1 statement, got: %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected: *ast.ExpressionStatement, got: %T",
+			program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("Expected: *ast.StringLiteral, got: %T",
+			stmt.Expression)
+	}
+
+	if literal.Value != "hello \"world\"\n" {
+		t.Errorf("Expected literal value: %q, got: %q", "hello \"world\"\n", literal.Value)
+	}
+
+	if literal.String() != input[:len(input)-1] {
+		t.Errorf("Expected string: %s, got: %s", input[:len(input)-1], literal.String())
+	}
+}
+
+func TestParsingTemplateLiterals(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedParts []interface{}
+		expected      string
+	}{
+		{`"total: ${a + b}!"`, []interface{}{"total: ", "(a + b)", "!"}, `"total: ${(a + b)}!"`},
+		{`"${x}${y}"`, []interface{}{"", "x", "", "y", ""}, `"${x}${y}"`},
+		{`"a ${"b ${c}"} \${d}"`, []interface{}{"a ", `"b ${c}"`, " ${d}"}, `"a ${"b ${c}"} \${d}"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected: 1 statement, got: %d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		template, ok := stmt.Expression.(*ast.TemplateLiteral)
+		if !ok {
+			t.Fatalf("Expected: *ast.TemplateLiteral, got: %T", stmt.Expression)
+		}
+
+		if len(template.Parts) != len(tt.expectedParts) {
+			t.Fatalf("Expected: %d parts, got: %d", len(tt.expectedParts), len(template.Parts))
+		}
+
+		for i, part := range template.Parts {
+			if i%2 == 0 {
+				text, ok := part.(*ast.StringLiteral)
+				if !ok {
+					t.Fatalf("Expected: *ast.StringLiteral, got: %T", part)
+				}
+				if text.Value != tt.expectedParts[i] {
+					t.Errorf("Expected text: %q, got: %q", tt.expectedParts[i], text.Value)
+				}
+			} else if part.String() != tt.expectedParts[i] {
+				t.Errorf("Expected expression: %s, got: %s", tt.expectedParts[i], part.String())
+			}
+		}
+
+		if template.String() != tt.expected {
+			t.Errorf("Expected: %s, got: %s", tt.expected, template.String())
+		}
+	}
+}
+
+func TestParsingTemplateLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"a ${} b"`, "1:6: empty expression in template string"},
+		{`"a ${x y} b"`, `1:8: expected '}' to close the embedded expression, found identifier "y"`},
+		{`"a ${x} \q"`, `1:9: unknown escape sequence: \q`},
+	}
+
+	for _, tt := range tests {
+		checkParserError(t, tt.input, tt.expectedError)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -239,6 +530,11 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 && 5;", 5, "&&", 5},
+		{"5 || 5;", 5, "||", 5},
 	}
 
 	for _, tt := range infixTests {
@@ -272,6 +568,12 @@ func TestParsingInfixExpressions(t *testing.T) {
 		if !testIntegerLiteral(t, expr.Right, tt.rightValue) {
 			return
 		}
+
+		shortCircuit := tt.operator == "&&" || tt.operator == "||"
+		if expr.ShortCircuit != shortCircuit {
+			t.Errorf("Expected short-circuit: %t for %s, got: %t",
+				shortCircuit, tt.operator, expr.ShortCircuit)
+		}
 	}
 }
 
@@ -294,80 +596,679 @@ func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	return true
 }
 
-func TestOperatorPrecedenceParsing(t *testing.T) {
+func TestParsingBooleanExpressions(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected string
+		input           string
+		expectedBoolean bool
 	}{
-		{
-			"-a * b",
-			"((-a) * b)",
-		},
-		{
-			"!-a",
-			"(!(-a))",
-		},
-		{
-			"a + b + c",
-			"((a + b) + c)",
-		},
-		{
-			"a + b - c",
-			"((a + b) - c)",
-		},
-		{
-			"a * b * c",
-			"((a * b) * c)",
-		},
-		{
-			"a * b / c",
-			"((a * b) / c)",
-		},
-		{
-			"a + b / c",
-			"(a + (b / c))",
-		},
-		{
-			"a + b * c + d / e - f",
-			"(((a + (b * c)) + (d / e)) - f)",
-		},
-		{
-			"3 + 4; -5 * 5",
-			"(3 + 4)((-5) * 5)",
-		},
-		{
-			"5 < 4 != 3 > 4",
-			"((5 < 4) != (3 > 4))",
-		},
-		{
-			"3 + 4 * 5 == 3 * 1 + 4 * 5",
-			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
-		},
-		{
-			"3 + 4 * 5 == 3 * 1 + 4 * 5",
-			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
-		},
+		{"true;", true},
+		{"false;", false},
 	}
 
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
 		p := New(l)
 		program := p.ParseProgram()
-
 		checkParserErrors(t, p)
 
-		actual := program.String()
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected: 1 statement, got: %d", len(program.Statements))
+		}
 
-		if actual != tt.expected {
-			t.Errorf("Expected: %s, got: %s", tt.expected, actual)
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Expected: *ast.ExpressionStatement, got: %T", program.Statements[0])
+		}
+
+		if !testBooleanLiteral(t, stmt.Expression, tt.expectedBoolean) {
+			return
 		}
 	}
 }
 
-////////////////////////////////////////////////////////////////////
-//                             UTILS 			                  //
-////////////////////////////////////////////////////////////////////
-
+func testBooleanLiteral(t *testing.T, expr ast.Expression, value bool) bool {
+	boolean, ok := expr.(*ast.Boolean)
+	if !ok {
+		t.Errorf("Expected: *ast.Boolean, got: %T", expr)
+		return false
+	}
+
+	if boolean.Value != value {
+		t.Errorf("Expected boolean value: %t, got: %t", value, boolean.Value)
+		return false
+	}
+
+	if boolean.TokenLiteral() != fmt.Sprintf("%t", value) {
+		t.Errorf("Expected boolean token literal: %t, got: %s", value, boolean.TokenLiteral())
+		return false
+	}
+
+	return true
+}
+
+func TestParsingGroupedExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"(1 + 2", "1:7: expected ')', found end of input"},
+		{"(1 + 2\n3", "1:7: expected ')', found end of line"},
+		{"(1 + 2 3)", "1:8: expected ')', found number 3"},
+	}
+
+	for _, tt := range tests {
+		checkParserError(t, tt.input, tt.expectedError)
+	}
+}
+
+func TestParsingIfExpressions(t *testing.T) {
+	input := `if (x < y) { x }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Expected: 1 statement, got: %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected: *ast.ExpressionStatement, got: %T", program.Statements[0])
+	}
+
+	expr, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("Expected: *ast.IfExpression, got: %T", stmt.Expression)
+	}
+
+	if !testLiteralExpression(t, expr.Condition, "(x < y)") {
+		return
+	}
+
+	if len(expr.Consequence.Statements) != 1 {
+		t.Fatalf("Expected: 1 consequence statement, got: %d", len(expr.Consequence.Statements))
+	}
+
+	consequence, ok := expr.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected: *ast.ExpressionStatement, got: %T", expr.Consequence.Statements[0])
+	}
+
+	if !testIdentifier(t, consequence.Expression, "x") {
+		return
+	}
+
+	if expr.Alternative != nil {
+		t.Errorf("Expected no alternative, got: %q", expr.Alternative.String())
+	}
+}
+
+func TestParsingIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"if (x < y) { x } else { y }",
+			"if (x < y) { x } else { y }",
+		},
+		{
+			"if (a) { 1 } else if (b) { 2 } else if (c) { 3 } else { 4 }",
+			"if a { 1 } else { if b { 2 } else { if c { 3 } else { 4 } } }",
+		},
+		{
+			"let max = if (a > b) { a } else { b }; max",
+			"let max = if (a > b) { a } else { b };max",
+		},
+		{
+			"if (ok) {\n  let x = 1\n  return x\n} else {\n  return;\n}\n",
+			"if ok { let x = 1; return x; } else { return; }",
+		},
+		{
+			"if (x) {}",
+			"if x { }",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("Expected: %s, got: %s", tt.expected, program.String())
+		}
+	}
+}
+
+func TestParsingIfExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"if (x) y }", `1:8: expected '{' to start the block of if, found identifier "y"`},
+		{"if (x) { y } else z", `1:19: expected '{' to start the block of else, found identifier "z"`},
+		{"if (x) {\n  y\n", "3:1: expected '}' to close the block opened at 1:8, found end of input"},
+		{"if x { y }", `1:4: expected '(', found identifier "x"`},
+	}
+
+	for _, tt := range tests {
+		checkParserError(t, tt.input, tt.expectedError)
+	}
+}
+
+func TestParsingFunctionLiterals(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Expected: 1 statement, got: %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected: *ast.ExpressionStatement, got: %T", program.Statements[0])
+	}
+
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("Expected: *ast.FunctionLiteral, got: %T", stmt.Expression)
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("Expected: 2 parameters, got: %d", len(function.Parameters))
+	}
+
+	testIdentifier(t, function.Parameters[0], "x")
+	testIdentifier(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("Expected: 1 body statement, got: %d", len(function.Body.Statements))
+	}
+
+	body, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected: *ast.ExpressionStatement, got: %T", function.Body.Statements[0])
+	}
+
+	testLiteralExpression(t, body.Expression, "(x + y)")
+}
+
+func TestParsingFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{"fn() {};", []string{}},
+		{"fn(x) {};", []string{"x"}},
+		{"fn(x, y, z) {};", []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("Expected: %d parameters, got: %d", len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testIdentifier(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+func TestParsingCallExpressions(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Expected: 1 statement, got: %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected: *ast.ExpressionStatement, got: %T", program.Statements[0])
+	}
+
+	expr, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("Expected: *ast.CallExpression, got: %T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, expr.Function, "add") {
+		return
+	}
+
+	if len(expr.Arguments) != 3 {
+		t.Fatalf("Expected: 3 arguments, got: %d", len(expr.Arguments))
+	}
+
+	testLiteralExpression(t, expr.Arguments[0], 1)
+	testLiteralExpression(t, expr.Arguments[1], "(2 * 3)")
+	testLiteralExpression(t, expr.Arguments[2], "(4 + 5)")
+}
+
+func TestParsingFunctionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"fn(x, 1) { x }", "1:7: expected parameter name, found number 1"},
+		{"fn(x,) { x }", "1:6: expected parameter name, found ')'"},
+		{"fn(x y) { x }", `1:6: expected ')', found identifier "y"`},
+		{"fn(a, b, a) { a }", "1:10: duplicate parameter a"},
+		{"let f = fn(x, 1) { x }; f(2)", "1:15: expected parameter name, found number 1"},
+		{"let f = fn(a, a) {\n  a\n}\nf(1)", "1:15: duplicate parameter a"},
+		{"fn x { x }", `1:4: expected '(', found identifier "x"`},
+		{"fn(x) x", `1:7: expected '{' to start the block of fn, found identifier "x"`},
+		{"add(1, 2", "1:9: expected ')', found end of input"},
+	}
+
+	for _, tt := range tests {
+		checkParserError(t, tt.input, tt.expectedError)
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedElements []interface{}
+	}{
+		{"[]", []interface{}{}},
+		{"[1, 2 * 2, x + 3]", []interface{}{1, "(2 * 2)", "(x + 3)"}},
+		{"[\n  1,\n  true,\n]", []interface{}{1, true}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected: 1 statement, got: %d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		array, ok := stmt.Expression.(*ast.ArrayLiteral)
+		if !ok {
+			t.Fatalf("Expected: *ast.ArrayLiteral, got: %T", stmt.Expression)
+		}
+
+		if len(array.Elements) != len(tt.expectedElements) {
+			t.Fatalf("Expected: %d elements, got: %d", len(tt.expectedElements), len(array.Elements))
+		}
+
+		for i, el := range tt.expectedElements {
+			testLiteralExpression(t, array.Elements[i], el)
+		}
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	expr, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("Expected: *ast.IndexExpression, got: %T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, expr.Left, "myArray") {
+		return
+	}
+
+	testLiteralExpression(t, expr.Index, "(1 + 1)")
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedLow  interface{}
+		expectedHigh interface{}
+	}{
+		{"arr[1:3]", 1, 3},
+		{"arr[:n]", nil, "n"},
+		{"arr[i + 1:]", "(i + 1)", nil},
+		{"arr[:]", nil, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		expr, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("Expected: *ast.SliceExpression, got: %T", stmt.Expression)
+		}
+
+		if !testIdentifier(t, expr.Left, "arr") {
+			return
+		}
+
+		for _, bound := range []struct {
+			expr     ast.Expression
+			expected interface{}
+		}{{expr.Low, tt.expectedLow}, {expr.High, tt.expectedHigh}} {
+			if bound.expected == nil {
+				if bound.expr != nil {
+					t.Errorf("Expected no bound in %s, got: %q", tt.input, bound.expr.String())
+				}
+				continue
+			}
+
+			testLiteralExpression(t, bound.expr, bound.expected)
+		}
+	}
+}
+
+func TestParsingIndexExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"arr[1", "1:6: expected ']', found end of input"},
+		{"arr[1:2:3]", "1:8: expected ']', found ':'"},
+		{"[1, 2", "1:6: expected ']', found end of input"},
+	}
+
+	for _, tt := range tests {
+		checkParserError(t, tt.input, tt.expectedError)
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedPairs [][2]interface{}
+	}{
+		{"{}", [][2]interface{}{}},
+		{`{"name": "x", 1: true, key: value}`, [][2]interface{}{
+			{`"name"`, `"x"`}, {1, true}, {"key", "value"},
+		}},
+		{"{\n  a + 1: [1],\n  f(): fn() {},\n}", [][2]interface{}{
+			{"(a + 1)", "[1]"}, {"f()", "fn() { }"},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected: 1 statement, got: %d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		hash, ok := stmt.Expression.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("Expected: *ast.HashLiteral, got: %T", stmt.Expression)
+		}
+
+		if len(hash.Pairs) != len(tt.expectedPairs) {
+			t.Fatalf("Expected: %d pairs, got: %d", len(tt.expectedPairs), len(hash.Pairs))
+		}
+
+		for i, pair := range tt.expectedPairs {
+			testLiteralExpression(t, hash.Pairs[i].Key, pair[0])
+			testLiteralExpression(t, hash.Pairs[i].Value, pair[1])
+		}
+	}
+}
+
+func TestParsingHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`{"a" 1}`, "1:6: expected ':' after hash key, found number 1"},
+		{`{"a": }`, `1:7: expected value for hash key "a", found '}'`},
+		{`{"a": 1, "b":, "c": 3}`, `1:14: expected value for hash key "b", found ','`},
+		{`{"a": 1 "b": 2}`, `1:9: expected ',', found string "b"`},
+		{`{"a": 1`, "1:8: expected ',', found end of input"},
+		{`fn() { let h = {"a" 1}; h }`, "1:21: expected ':' after hash key, found number 1"},
+		{`fn() { {"a": {"b": }} }`, `1:20: expected value for hash key "b", found '}'`},
+	}
+
+	for _, tt := range tests {
+		checkParserError(t, tt.input, tt.expectedError)
+	}
+}
+
+// The testLiteralExpression(...) function checks integers and
+// identifiers by value. Any other expression is compared by its String().
+func testLiteralExpression(t *testing.T, expr ast.Expression, expected interface{}) bool {
+	if expr == nil {
+		t.Errorf("Expected expression: %v, got: nil", expected)
+		return false
+	}
+
+	switch v := expected.(type) {
+	case int:
+		return testIntegerLiteral(t, expr, int64(v))
+	case bool:
+		return testBooleanLiteral(t, expr, v)
+	case string:
+		if _, ok := expr.(*ast.Identifier); ok {
+			return testIdentifier(t, expr, v)
+		}
+
+		if expr.String() != v {
+			t.Errorf("Expected expression: %q, got: %q", v, expr.String())
+			return false
+		}
+		return true
+	}
+
+	t.Errorf("Type of expected value not handled, got: %T", expected)
+	return false
+}
+
+func TestOperatorPrecedenceParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"-a * b",
+			"((-a) * b)",
+		},
+		{
+			"!-a",
+			"(!(-a))",
+		},
+		{
+			"a + b + c",
+			"((a + b) + c)",
+		},
+		{
+			"a + b - c",
+			"((a + b) - c)",
+		},
+		{
+			"a * b * c",
+			"((a * b) * c)",
+		},
+		{
+			"a * b / c",
+			"((a * b) / c)",
+		},
+		{
+			"a + b / c",
+			"(a + (b / c))",
+		},
+		{
+			"a + b * c + d / e - f",
+			"(((a + (b * c)) + (d / e)) - f)",
+		},
+		{
+			"3 + 4; -5 * 5",
+			"(3 + 4)((-5) * 5)",
+		},
+		{
+			"5 < 4 != 3 > 4",
+			"((5 < 4) != (3 > 4))",
+		},
+		{
+			"3 + 4 * 5 == 3 * 1 + 4 * 5",
+			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
+		},
+		{
+			"3 + 4 * 5 == 3 * 1 + 4 * 5",
+			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
+		},
+		{
+			"a % b * c",
+			"((a % b) * c)",
+		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c != d || !e",
+			"(((a == b) && (c != d)) || (!e))",
+		},
+		{
+			`"a" + "b" == -"c"`,
+			`(("a" + "b") == (-"c"))`,
+		},
+		{
+			"true",
+			"true",
+		},
+		{
+			"3 > 5 == false",
+			"((3 > 5) == false)",
+		},
+		{
+			"!true != !false",
+			"((!true) != (!false))",
+		},
+		{
+			"1 + (2 + 3) + 4",
+			"((1 + (2 + 3)) + 4)",
+		},
+		{
+			"(5 + 5) * 2",
+			"((5 + 5) * 2)",
+		},
+		{
+			"2 / (5 + 5)",
+			"(2 / (5 + 5))",
+		},
+		{
+			"-(5 + 5)",
+			"(-(5 + 5))",
+		},
+		{
+			"!(true == true)",
+			"(!(true == true))",
+		},
+		{
+			"(a || b) && c",
+			"((a || b) && c)",
+		},
+		{
+			"a + add(b * c) + d",
+			"((a + add((b * c))) + d)",
+		},
+		{
+			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
+		},
+		{
+			"add(a + b + c * d / f + g)",
+			"add((((a + b) + ((c * d) / f)) + g))",
+		},
+		{
+			"-f(x) * g()",
+			"((-f(x)) * g())",
+		},
+		{
+			"fn(x) { x }(5)",
+			"fn(x) { x }(5)",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"-a[1:n - 1]",
+			"(-(a[1:(n - 1)]))",
+		},
+		{
+			"fns[0](x)[1]",
+			"((fns[0])(x)[1])",
+		},
+		{
+			`{"a": 1 + 2, b: [c][0]}["a"]`,
+			`({"a": (1 + 2), b: ([c][0])}["a"])`,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		checkParserErrors(t, p)
+
+		actual := program.String()
+
+		if actual != tt.expected {
+			t.Errorf("Expected: %s, got: %s", tt.expected, actual)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////
+//                             UTILS 			                  //
+////////////////////////////////////////////////////////////////////
+
 func checkParserErrors(t *testing.T, parser *Parser) {
 	errors := parser.errors
 
@@ -377,9 +1278,22 @@ func checkParserErrors(t *testing.T, parser *Parser) {
 
 	t.Errorf("Parser encountered: %d errors.", len(errors))
 
-	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
+	for _, err := range errors {
+		t.Errorf("parser error: %q", err.Error())
 	}
 
 	t.FailNow()
 }
+
+// The checkParserError(...) function parses the input and checks that it
+// gives exactly the expected error. Any follow-up error is a failure too.
+func checkParserError(t *testing.T, input string, expected string) {
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0].Error() != expected {
+		t.Errorf("%q - expected error: %q, got: %q", input, expected, errors)
+	}
+}
@@ -1,32 +1,133 @@
 package parser
 
 import (
+	"encoding/json"
 	"fmt"
+	"goparsor/token"
+	"io"
 	"strings"
 )
 
-var traceLevel int = 0
+// Tracer is told about every parse function the parser enters and
+// leaves, along with the current and peek tokens at that moment. Each
+// parser has its own tracer, see WithTracer(...), so the tracers that
+// ship with the package keep their state per instance and are not safe
+// to share between parsers.
+type Tracer interface {
+	Enter(fn string, curr, peek token.Token)
+	Exit(fn string, curr, peek token.Token)
+}
+
+func (p *Parser) trace(fn string) string {
+	if p.tracer != nil {
+		p.tracer.Enter(fn, p.currToken, p.peekToken)
+	}
+
+	return fn
+}
+
+func (p *Parser) untrace(fn string) {
+	if p.tracer != nil {
+		p.tracer.Exit(fn, p.currToken, p.peekToken)
+	}
+}
+
+/*~*~*~*~*~*~*~*~*~*~*~*~* Text Tracer ~*~*~*~*~*~*~*~*~*~*~*~*~*/
 
 const traceIdentPlaceholder string = "\t"
 
-func identLevel() string {
-	return strings.Repeat(traceIdentPlaceholder, traceLevel-1)
+type textTracer struct {
+	w     io.Writer
+	level int
+}
+
+// NewTextTracer returns a tracer that writes one line per call, indented
+// by how deep the parse function is nested, e.g.
+//
+//	BEGIN parsePrefixExpression (- "-", peek IDENT "x")
+func NewTextTracer(w io.Writer) Tracer {
+	return &textTracer{w: w}
+}
+
+func (t *textTracer) Enter(fn string, curr, peek token.Token) {
+	t.print("BEGIN", fn, curr, peek)
+	t.level += 1
+}
+
+func (t *textTracer) Exit(fn string, curr, peek token.Token) {
+	t.level -= 1
+	t.print("END", fn, curr, peek)
 }
 
-func tracePrint(fs string) {
-	fmt.Printf("%s%s\n", identLevel(), fs)
+func (t *textTracer) print(event, fn string, curr, peek token.Token) {
+	fmt.Fprintf(t.w, "%s%s %s (%s %q, peek %s %q)\n",
+		strings.Repeat(traceIdentPlaceholder, t.level), event, fn,
+		curr.Type, curr.Literal, peek.Type, peek.Literal)
 }
 
-func incIdent() { traceLevel = traceLevel + 1 }
-func decIdent() { traceLevel = traceLevel - 1 }
+/*~*~*~*~*~*~*~*~*~*~*~*~* JSON Tracer ~*~*~*~*~*~*~*~*~*~*~*~*~*/
+
+// JSON Lines format: one event per line, with the positions stored as
+// [offset, line, column] like in token streams, e.g.
+//
+//	{"event":"enter","func":"parseExpression","depth":1,
+//	 "curr":{"type":"IDENT","literal":"x","start":[0,1,1]},
+//	 "peek":{"type":";","literal":"","start":[1,1,2]}}
+type jsonTraceEvent struct {
+	Event string         `json:"event"`
+	Func  string         `json:"func"`
+	Depth int            `json:"depth"`
+	Curr  jsonTraceToken `json:"curr"`
+	Peek  jsonTraceToken `json:"peek"`
+}
+
+type jsonTraceToken struct {
+	Type    string `json:"type"`
+	Literal string `json:"literal"`
+	Start   [3]int `json:"start"`
+}
+
+type jsonTracer struct {
+	enc   *json.Encoder
+	depth int
+}
+
+// NewJSONTracer returns a tracer that writes the events as JSON Lines,
+// for tools that visualise how the parser works.
+func NewJSONTracer(w io.Writer) Tracer {
+	enc := json.NewEncoder(w)
+	// Keep literals like "<" readable
+	enc.SetEscapeHTML(false)
+
+	return &jsonTracer{enc: enc}
+}
+
+func (t *jsonTracer) Enter(fn string, curr, peek token.Token) {
+	t.encode("enter", fn, curr, peek)
+	t.depth += 1
+}
+
+func (t *jsonTracer) Exit(fn string, curr, peek token.Token) {
+	t.depth -= 1
+	t.encode("exit", fn, curr, peek)
+}
 
-func trace(msg string) string {
-	incIdent()
-	tracePrint("BEGIN " + msg)
-	return msg
+func (t *jsonTracer) encode(event, fn string, curr, peek token.Token) {
+	// Tracing must not get in the way of parsing, write errors are
+	// ignored just like with the text tracer.
+	_ = t.enc.Encode(jsonTraceEvent{
+		Event: event,
+		Func:  fn,
+		Depth: t.depth,
+		Curr:  newJSONTraceToken(curr),
+		Peek:  newJSONTraceToken(peek),
+	})
 }
 
-func untrace(msg string) {
-	tracePrint("END " + msg)
-	decIdent()
+func newJSONTraceToken(tok token.Token) jsonTraceToken {
+	return jsonTraceToken{
+		Type:    tok.Type.String(),
+		Literal: tok.Literal,
+		Start:   [3]int{tok.Start.Offset, tok.Start.Line, tok.Start.Column},
+	}
 }
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"goparsor/ast"
+	"goparsor/token"
+)
+
+// Error recovery works in panic mode. The first error in a statement
+// puts the parser in panic mode, where it stops reporting errors: they
+// would only be follow-ups of the first one. At the end of the statement
+// the parser skips ahead to the next statement boundary and leaves panic
+// mode, so that every real mistake is reported exactly once.
+//
+// The parts that could not be parsed are kept in the tree as
+// ast.BadStatement and ast.BadExpression nodes.
+
+// The badExpression(...) function returns the placeholder for an
+// expression that starts at the given token and ends at the current one.
+func (p *Parser) badExpression(start token.Token) ast.Expression {
+	return &ast.BadExpression{Token: start, From: start.Start, To: p.currToken.End}
+}
+
+// The synchronize(...) function skips the rest of a broken statement. It
+// stops at a semicolon, right before a "}" that closes the enclosing
+// block or right before a token that starts a new statement. Blocks in
+// the skipped code are skipped as a whole, and so is a stray "}" outside
+// of any block.
+func (p *Parser) synchronize() {
+	depth := 0
+
+	for {
+		switch p.currToken.Type {
+		case token.LBRACE:
+			depth += 1
+		case token.RBRACE:
+			if depth > 0 {
+				depth -= 1
+			}
+		}
+
+		if depth == 0 {
+			if p.currTokenIs(token.SEMICOLON) {
+				return
+			}
+
+			switch p.peekToken.Type {
+			case token.RBRACE:
+				if p.blockDepth > 0 {
+					return
+				}
+			case token.LET, token.RETURN:
+				return
+			case token.SEMICOLON:
+				p.NextToken()
+				return
+			}
+		}
+
+		if p.peekTokenIs(token.EOF) {
+			return
+		}
+
+		p.NextToken()
+	}
+}
+
+// The skipToClose(...) function skips the rest of a broken construct
+// that was opened before the current token, e.g. a hash literal, and
+// stops on the token that closes it. Nested constructs are skipped as a
+// whole. If the construct is never closed, it stops right before the next
+// statement or at the end of the input, so that synchronize(...) does not
+// take the closing token for the end of an enclosing block.
+func (p *Parser) skipToClose(open, close token.TokenType) {
+	depth := 1
+
+	for !p.peekTokenIs(token.EOF) {
+		if depth == 1 && (p.peekTokenIs(token.LET) || p.peekTokenIs(token.RETURN)) {
+			return
+		}
+
+		p.NextToken()
+
+		switch p.currToken.Type {
+		case open:
+			depth += 1
+		case close:
+			depth -= 1
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// The backup(...) function moves the parser back by one token. It is
+// used when an expression is missing right before a closing token, e.g.
+// in "(1 + )", so that the ")" can still close what it was meant to.
+func (p *Parser) backup() {
+	p.pending = append(p.pending, p.peekToken)
+	p.peekToken = p.currToken
+	p.currToken = p.prevToken
+}
+
+// The closesConstruct(...) function reports if a token can only end
+// something, like a group, a block or a statement.
+func closesConstruct(tkType token.TokenType) bool {
+	switch tkType {
+	case token.RPAREN, token.RBRACE, token.RBRACKET, token.SEMICOLON, token.COMMA, token.EOF:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"goparsor/ast"
+	"goparsor/lexer"
+	"testing"
+	"time"
+)
+
+func TestRecoveryReportsEachMistakeOnce(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedErrors   []string
+		expectedProgram  string
+		expectedBadCount int
+	}{
+		{
+			"let x 5 + 6; let y = 2;",
+			[]string{"1:7: expected '=', found number 5"},
+			"<bad statement>let y = 2;",
+			1,
+		},
+		{
+			"let = 1 + ; y",
+			[]string{"1:5: expected identifier, found '='"},
+			"<bad statement>y",
+			1,
+		},
+		{
+			"let a = (1 + ); let b = [1, , 2]; c",
+			[]string{
+				"1:14: expected expression, found ')'",
+				"1:29: expected expression, found ','",
+			},
+			"let a = (1 + <bad expression>);let b = [1, <bad expression>, 2];c",
+			2,
+		},
+		{
+			"let f = fn(x) {\n  let = x\n  return x\n}\nf(1)",
+			[]string{"2:7: expected identifier, found '='"},
+			"let f = fn(x) { <bad statement> return x; };f(1)",
+			1,
+		},
+		{
+			"x @ 1; y",
+			[]string{"1:3: unexpected character '@'"},
+			"x<bad expression>y",
+			1,
+		},
+		{
+			"}; let x = 1",
+			[]string{"1:1: expected expression, found '}'"},
+			"<bad expression>let x = 1;",
+			1,
+		},
+		{
+			"if (x) y }; let q = 1",
+			[]string{`1:8: expected '{' to start the block of if, found identifier "y"`},
+			"<bad expression>let q = 1;",
+			1,
+		},
+		{
+			"let x = {1: 2, 3: }; let q = 1;",
+			[]string{"1:19: expected value for hash key 3, found '}'"},
+			"let x = <bad expression>;let q = 1;",
+			1,
+		},
+		{
+			`let h = {"a": 1 "b": 2, "c": {"d": 3}}; let q = 1;`,
+			[]string{`1:17: expected ',', found string "b"`},
+			"let h = <bad expression>;let q = 1;",
+			1,
+		},
+		{
+			"let h = {1 2\nlet q = 1",
+			[]string{"1:12: expected ':' after hash key, found number 2"},
+			"let h = <bad expression>;let q = 1;",
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Fatalf("%q - expected %d errors, got: %q", tt.input, len(tt.expectedErrors), errors)
+		}
+
+		for i, msg := range tt.expectedErrors {
+			if errors[i].Error() != msg {
+				t.Errorf("%q - expected error: %s, got: %s", tt.input, msg, errors[i])
+			}
+		}
+
+		if program.String() != tt.expectedProgram {
+			t.Errorf("%q - expected program: %s, got: %s", tt.input, tt.expectedProgram, program.String())
+		}
+
+		if bad := countBadNodes(program); bad != tt.expectedBadCount {
+			t.Errorf("%q - expected %d bad nodes, got: %d", tt.input, tt.expectedBadCount, bad)
+		}
+	}
+}
+
+func TestBadStatementSpansBrokenRange(t *testing.T) {
+	input := "let 5 = x + 1;\nok"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	bad, ok := program.Statements[0].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("Expected: *ast.BadStatement, got: %T", program.Statements[0])
+	}
+
+	if bad.From.Offset != 0 || bad.To.Offset != 14 {
+		t.Errorf("Expected bad statement to span [0:14], got: [%d:%d]", bad.From.Offset, bad.To.Offset)
+	}
+
+	if _, ok := program.Statements[1].(*ast.ExpressionStatement); !ok {
+		t.Errorf("Expected: *ast.ExpressionStatement, got: %T", program.Statements[1])
+	}
+}
+
+func TestRecoveryAlwaysTerminates(t *testing.T) {
+	source := "let a = fn(x, y) { if (x > y) { x } else { [y, {\"k\": y}][0:1] } };\nlet s = \"v ${a(1, 2)}\"\nreturn s\n"
+	inserts := []string{"", "(", ")", "{", "}", "[", "]", ",", ":", ";", "\n", "let", "=", "+", "\"", "${"}
+
+	for offset := 0; offset <= len(source); offset++ {
+		for deleted := 0; deleted <= 2 && offset+deleted <= len(source); deleted++ {
+			for _, insert := range inserts {
+				input := source[:offset] + insert + source[offset+deleted:]
+
+				done := make(chan *ast.Program)
+				go func() {
+					done <- New(lexer.New(input)).ParseProgram()
+				}()
+
+				select {
+				case program := <-done:
+					// Printing walks the whole tree, so it would panic on
+					// any nil node left behind by an error.
+					_ = program.String()
+				case <-time.After(time.Second):
+					t.Fatalf("ParseProgram(...) does not terminate for %q", input)
+				}
+			}
+		}
+	}
+}
+
+// The countBadNodes(...) function counts the Bad* nodes in the
+// statements of a program and in the blocks of its functions.
+func countBadNodes(program *ast.Program) int {
+	count := 0
+
+	var statements func([]ast.Statement)
+	var expression func(ast.Expression)
+
+	statements = func(stmts []ast.Statement) {
+		for _, stmt := range stmts {
+			switch stmt := stmt.(type) {
+			case *ast.BadStatement:
+				count += 1
+			case *ast.LetStatement:
+				expression(stmt.Value)
+			case *ast.ReturnStatement:
+				expression(stmt.ReturnValue)
+			case *ast.ExpressionStatement:
+				expression(stmt.Expression)
+			}
+		}
+	}
+
+	expression = func(expr ast.Expression) {
+		switch expr := expr.(type) {
+		case *ast.BadExpression:
+			count += 1
+		case *ast.InfixExpression:
+			expression(expr.Left)
+			expression(expr.Right)
+		case *ast.ArrayLiteral:
+			for _, el := range expr.Elements {
+				expression(el)
+			}
+		case *ast.FunctionLiteral:
+			statements(expr.Body.Statements)
+		}
+	}
+
+	statements(program.Statements)
+
+	return count
+}
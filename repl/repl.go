@@ -6,6 +6,7 @@ import (
 	"goparsor/lexer"
 	"goparsor/token"
 	"io"
+	"os"
 )
 
 const PROMPT = ">> "
@@ -25,11 +26,14 @@ func Start(in io.Reader) {
 
 		l := lexer.New(line)
 
+		// Tokens are printed as JSON Lines, so that the output can be
+		// piped to other tools as well.
+		enc := token.NewJSONEncoder(os.Stdout)
 		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			// % is the indicator of the start of a format specifier
-			// + is used to present struct field names
-			// v specifies the default format: show values
-			fmt.Printf("%+v\n", tok)
+			if err := enc.Encode(tok); err != nil {
+				fmt.Fprintf(os.Stderr, "could not print token: %s\n", err)
+				return
+			}
 		}
 	}
 }
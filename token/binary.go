@@ -0,0 +1,227 @@
+package token
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Binary format: the header "GPTK" followed by the format version, then
+// one record per token. All numbers are varints:
+//
+//	type    index into the table of types seen so far. An index equal to
+//	        the size of the table adds a new type, its name follows as
+//	        length + bytes.
+//	literal length + bytes
+//	start   offset, line and column, relative to the end of the
+//	        previous token
+//	end     offset, line and column, relative to the start
+//
+// Storing the positions as deltas keeps most of them in a single byte.
+const (
+	binaryMagic     = "GPTK"
+	binaryVersion   = 1
+	maxBinaryString = 1 << 30
+)
+
+type BinaryEncoder struct {
+	w       io.Writer
+	buf     []byte
+	types   map[TokenType]int
+	last    Position
+	started bool
+}
+
+func NewBinaryEncoder(w io.Writer) *BinaryEncoder {
+	return &BinaryEncoder{w: w, types: make(map[TokenType]int)}
+}
+
+func (e *BinaryEncoder) Encode(tok Token) error {
+	e.buf = e.buf[:0]
+
+	if !e.started {
+		e.buf = append(e.buf, binaryMagic...)
+		e.buf = append(e.buf, binaryVersion)
+		e.started = true
+	}
+
+	index, ok := e.types[tok.Type]
+	if !ok {
+		index = len(e.types)
+		e.types[tok.Type] = index
+	}
+	e.buf = binary.AppendUvarint(e.buf, uint64(index))
+	if !ok {
+		e.buf = appendString(e.buf, tok.Type.String())
+	}
+
+	e.buf = appendString(e.buf, tok.Literal)
+	e.buf = appendPosition(e.buf, tok.Start, e.last)
+	e.buf = appendPosition(e.buf, tok.End, tok.Start)
+	e.last = tok.End
+
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendPosition(buf []byte, pos, base Position) []byte {
+	buf = binary.AppendVarint(buf, int64(pos.Offset-base.Offset))
+	buf = binary.AppendVarint(buf, int64(pos.Line-base.Line))
+	return binary.AppendVarint(buf, int64(pos.Column-base.Column))
+}
+
+// BinaryDecoder reads tokens written by BinaryEncoder. It is a Source,
+// so the parser can use it in place of the lexer.
+type BinaryDecoder struct {
+	r       *bufio.Reader
+	types   []TokenType
+	last    Token
+	count   int
+	started bool
+	done    bool
+	err     error
+}
+
+func NewBinaryDecoder(r io.Reader) *BinaryDecoder {
+	return &BinaryDecoder{r: bufio.NewReader(r)}
+}
+
+// Err returns the error that stopped the decoder, if any.
+func (d *BinaryDecoder) Err() error {
+	return d.err
+}
+
+func (d *BinaryDecoder) NextToken() Token {
+	if d.done {
+		return eofAfter(d.last)
+	}
+
+	tok, err := d.decode()
+	if err != nil {
+		d.done = true
+		// Running out of input between two tokens is how the stream ends,
+		// anywhere else it means the data is cut short.
+		if !errors.Is(err, io.EOF) {
+			d.err = fmt.Errorf("token %d: %w", d.count, err)
+		}
+		return eofAfter(d.last)
+	}
+
+	d.count += 1
+	d.last = tok
+	d.done = tok.Type == EOF
+
+	return tok
+}
+
+func (d *BinaryDecoder) decode() (Token, error) {
+	var tok Token
+
+	if !d.started {
+		header := make([]byte, len(binaryMagic)+1)
+		if _, err := io.ReadFull(d.r, header); err != nil {
+			return tok, err
+		}
+
+		if string(header[:len(binaryMagic)]) != binaryMagic {
+			return tok, errors.New("not a binary token stream")
+		}
+
+		if header[len(binaryMagic)] != binaryVersion {
+			return tok, fmt.Errorf("unsupported version: %d", header[len(binaryMagic)])
+		}
+
+		d.started = true
+	}
+
+	index, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return tok, err
+	}
+
+	var ok bool
+
+	// From here on the token is incomplete, so EOF is unexpected
+	switch {
+	case index < uint64(len(d.types)):
+		tok.Type = d.types[index]
+	case index == uint64(len(d.types)):
+		name, err := d.readString()
+		if err != nil {
+			return tok, unexpectedEOF(err)
+		}
+		if tok.Type, ok = TypeByName(name); !ok {
+			return tok, fmt.Errorf("unknown token type: %q", name)
+		}
+		d.types = append(d.types, tok.Type)
+	default:
+		return tok, fmt.Errorf("unknown token type index: %d", index)
+	}
+
+	if tok.Literal, err = d.readString(); err != nil {
+		return tok, unexpectedEOF(err)
+	}
+
+	if tok.Start, err = d.readPosition(d.last.End); err != nil {
+		return tok, unexpectedEOF(err)
+	}
+
+	if tok.End, err = d.readPosition(tok.Start); err != nil {
+		return tok, unexpectedEOF(err)
+	}
+
+	return tok, nil
+}
+
+func (d *BinaryDecoder) readString() (string, error) {
+	length, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return "", err
+	}
+
+	// Don't trust the length blindly, a corrupted stream could ask
+	// for an enormous buffer.
+	if length > maxBinaryString {
+		return "", fmt.Errorf("string too long: %d bytes", length)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+func (d *BinaryDecoder) readPosition(base Position) (Position, error) {
+	var deltas [3]int64
+
+	for i := range deltas {
+		delta, err := binary.ReadVarint(d.r)
+		if err != nil {
+			return Position{}, err
+		}
+		deltas[i] = delta
+	}
+
+	return Position{
+		Offset: base.Offset + int(deltas[0]),
+		Line:   base.Line + int(deltas[1]),
+		Column: base.Column + int(deltas[2]),
+	}, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
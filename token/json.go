@@ -0,0 +1,98 @@
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// JSON Lines format: one token per line, with the positions stored as
+// [offset, line, column], e.g.
+//
+//	{"type":"LET","literal":"let","start":[0,1,1],"end":[3,1,4]}
+type jsonToken struct {
+	Type    string `json:"type"`
+	Literal string `json:"literal"`
+	Start   [3]int `json:"start"`
+	End     [3]int `json:"end"`
+}
+
+type JSONEncoder struct {
+	enc *json.Encoder
+}
+
+func NewJSONEncoder(w io.Writer) *JSONEncoder {
+	enc := json.NewEncoder(w)
+	// Keep literals like "<" readable
+	enc.SetEscapeHTML(false)
+
+	return &JSONEncoder{enc: enc}
+}
+
+func (e *JSONEncoder) Encode(tok Token) error {
+	return e.enc.Encode(jsonToken{
+		Type:    tok.Type.String(),
+		Literal: tok.Literal,
+		Start:   [3]int{tok.Start.Offset, tok.Start.Line, tok.Start.Column},
+		End:     [3]int{tok.End.Offset, tok.End.Line, tok.End.Column},
+	})
+}
+
+// JSONDecoder reads tokens written by JSONEncoder. It is a Source, so
+// the parser can use it in place of the lexer.
+type JSONDecoder struct {
+	dec   *json.Decoder
+	count int
+	last  Token
+	done  bool
+	err   error
+}
+
+func NewJSONDecoder(r io.Reader) *JSONDecoder {
+	return &JSONDecoder{dec: json.NewDecoder(r)}
+}
+
+// Err returns the error that stopped the decoder, if any.
+func (d *JSONDecoder) Err() error {
+	return d.err
+}
+
+func (d *JSONDecoder) NextToken() Token {
+	if d.done {
+		return eofAfter(d.last)
+	}
+
+	var jt jsonToken
+	if err := d.dec.Decode(&jt); err != nil {
+		d.done = true
+		if !errors.Is(err, io.EOF) {
+			d.err = fmt.Errorf("token %d: %w", d.count, err)
+		}
+		return eofAfter(d.last)
+	}
+
+	if jt.Type == "" {
+		d.done = true
+		d.err = fmt.Errorf("token %d: missing token type", d.count)
+		return eofAfter(d.last)
+	}
+
+	typ, ok := TypeByName(jt.Type)
+	if !ok {
+		d.done = true
+		d.err = fmt.Errorf("token %d: unknown token type: %q", d.count, jt.Type)
+		return eofAfter(d.last)
+	}
+
+	d.count += 1
+	d.last = Token{
+		Type:    typ,
+		Literal: jt.Literal,
+		Start:   Position{Offset: jt.Start[0], Line: jt.Start[1], Column: jt.Start[2]},
+		End:     Position{Offset: jt.End[0], Line: jt.End[1], Column: jt.End[2]},
+	}
+	d.done = d.last.Type == EOF
+
+	return d.last
+}
@@ -0,0 +1,34 @@
+package token
+
+// Source is anything that hands out tokens one by one, like the lexer
+// or one of the decoders below. Once a source runs out of tokens it
+// keeps returning EOF.
+type Source interface {
+	NextToken() Token
+}
+
+// Encoder writes tokens in one of the serialization formats.
+type Encoder interface {
+	Encode(tok Token) error
+}
+
+// Copy encodes all the tokens of src, up to and including EOF.
+func Copy(enc Encoder, src Source) error {
+	for {
+		tok := src.NextToken()
+
+		if err := enc.Encode(tok); err != nil {
+			return err
+		}
+
+		if tok.Type == EOF {
+			return nil
+		}
+	}
+}
+
+// The eofAfter(...) function returns the EOF token a decoder hands out
+// when the stream ends, placed right after the last token it decoded.
+func eofAfter(last Token) Token {
+	return Token{Type: EOF, Start: last.End, End: last.End}
+}
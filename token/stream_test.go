@@ -0,0 +1,137 @@
+package token
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Tokens of: let s = "a<b";\n
+var streamTokens = []Token{
+	{Type: LET, Literal: "let", Start: Position{0, 1, 1}, End: Position{3, 1, 4}},
+	{Type: IDENT, Literal: "s", Start: Position{4, 1, 5}, End: Position{5, 1, 6}},
+	{Type: ASSIGN, Literal: "=", Start: Position{6, 1, 7}, End: Position{7, 1, 8}},
+	{Type: STRING, Literal: `"a<b"`, Start: Position{8, 1, 9}, End: Position{13, 1, 14}},
+	{Type: SEMICOLON, Literal: ";", Start: Position{13, 1, 14}, End: Position{14, 1, 15}},
+	{Type: EOF, Literal: "", Start: Position{15, 2, 1}, End: Position{15, 2, 1}},
+}
+
+type decoder interface {
+	Source
+	Err() error
+}
+
+type sliceSource struct {
+	tokens []Token
+}
+
+func (s *sliceSource) NextToken() Token {
+	tok := s.tokens[0]
+	if len(s.tokens) > 1 {
+		s.tokens = s.tokens[1:]
+	}
+
+	return tok
+}
+
+func TestTokenStreamRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		newEncoder func(io.Writer) Encoder
+		newDecoder func(io.Reader) decoder
+	}{
+		{
+			"json",
+			func(w io.Writer) Encoder { return NewJSONEncoder(w) },
+			func(r io.Reader) decoder { return NewJSONDecoder(r) },
+		},
+		{
+			"binary",
+			func(w io.Writer) Encoder { return NewBinaryEncoder(w) },
+			func(r io.Reader) decoder { return NewBinaryDecoder(r) },
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+
+		err := Copy(tt.newEncoder(&buf), &sliceSource{tokens: streamTokens})
+		if err != nil {
+			t.Fatalf("%s - could not encode: %s", tt.name, err)
+		}
+
+		dec := tt.newDecoder(&buf)
+
+		for i, expected := range streamTokens {
+			tok := dec.NextToken()
+			if tok != expected {
+				t.Fatalf("%s[%d] - token wrong. expected=%+v, got=%+v",
+					tt.name, i, expected, tok)
+			}
+		}
+
+		// Decoders keep returning EOF once the stream ends
+		if tok := dec.NextToken(); tok != streamTokens[len(streamTokens)-1] {
+			t.Fatalf("%s - expected EOF after the end, got=%+v", tt.name, tok)
+		}
+
+		if dec.Err() != nil {
+			t.Fatalf("%s - unexpected error: %s", tt.name, dec.Err())
+		}
+	}
+}
+
+func TestJSONEncoderFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := NewJSONEncoder(&buf).Encode(streamTokens[3]); err != nil {
+		t.Fatalf("could not encode: %s", err)
+	}
+
+	expected := `{"type":"STRING","literal":"\"a<b\"","start":[8,1,9],"end":[13,1,14]}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("Expected: %s, got: %s", expected, buf.String())
+	}
+}
+
+func TestTokenStreamDecodeErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Copy(NewBinaryEncoder(&buf), &sliceSource{tokens: streamTokens}); err != nil {
+		t.Fatalf("could not encode: %s", err)
+	}
+	binaryStream := buf.Bytes()
+
+	tests := []struct {
+		name           string
+		source         decoder
+		expectedTokens int
+		expectedError  string
+	}{
+		{"json syntax", NewJSONDecoder(strings.NewReader(`{"type":"LET"}` + "\n{oops")), 1, "token 1: invalid character 'o' looking for beginning of object key string"},
+		{"json no type", NewJSONDecoder(strings.NewReader(`{"literal":"x"}`)), 0, "token 0: missing token type"},
+		{"binary magic", NewBinaryDecoder(strings.NewReader("JUNK\x01")), 0, "token 0: not a binary token stream"},
+		{"binary cut", NewBinaryDecoder(bytes.NewReader(binaryStream[:len(binaryStream)-2])), 5, "token 5: unexpected EOF"},
+		{"binary empty", NewBinaryDecoder(bytes.NewReader(nil)), 0, ""},
+	}
+
+	for _, tt := range tests {
+		count := 0
+		for tok := tt.source.NextToken(); tok.Type != EOF; tok = tt.source.NextToken() {
+			count += 1
+		}
+
+		if count != tt.expectedTokens {
+			t.Errorf("%s - expected %d tokens, got: %d", tt.name, tt.expectedTokens, count)
+		}
+
+		actual := ""
+		if tt.source.Err() != nil {
+			actual = tt.source.Err().Error()
+		}
+
+		if actual != tt.expectedError {
+			t.Errorf("%s - expected error: %q, got: %q", tt.name, tt.expectedError, actual)
+		}
+	}
+}
@@ -1,55 +1,157 @@
 package token
 
+import "fmt"
+
 // Rob Pike used int probably for performance reasons.
-// Thorsten explains this in chapter 1.2 of his book.
-type TokenType string
+// Thorsten explains this in chapter 1.2 of his book. We do the same, so
+// comparing and storing token types never costs an allocation.
+type TokenType uint16
 
 type Token struct {
-	Type    TokenType
+	Type TokenType
+	// The source text of the token, e.g. a string literal keeps its
+	// quotes and escape sequences.
 	Literal string
+	// Start points to the first char of the token and End to the char
+	// right after the last one, so Literal is input[Start:End].
+	Start Position
+	End   Position
+	// Whitespace and comments around the token. They are only filled
+	// in by lexers in trivia mode, so that Leading + Literal + Trailing
+	// of all tokens gives back the source code.
+	Leading  string
+	Trailing string
+	// Semicolons inserted by the lexer have an empty Literal. AtEOF tells
+	// the ones inserted at the end of the input apart from the ones
+	// inserted at a newline. Like trivia, it is not kept by the stream
+	// encoders.
+	AtEOF bool
+}
+
+// Position describes a place in the source code. Offset is counted in
+// bytes from the start of the input, Line and Column start at 1.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
 	// Special tokens
-	ILLEGAL = "ILLEGAL"
-	EOF     = "EOF"
+	ILLEGAL TokenType = iota
+	EOF
+	COMMENT // Only emitted when the lexer is asked to
 
 	// Identifiers + literals
-	IDENT = "IDENT" // add, foobar, x, y, ...
-	INT   = "INT"   // Integer type
+	IDENT  // add, foobar, x, y, ...
+	INT    // Integer type
+	FLOAT  // 3.14, 1e-9
+	STRING // "foo bar"
+	// Template strings: "a ${x} b ${y} c" is lexed as HEAD `"a ${`,
+	// x, MIDDLE `} b ${`, y and TAIL `} c"`
+	TEMPLATE_HEAD
+	TEMPLATE_MIDDLE
+	TEMPLATE_TAIL
 
 	// Operators: Unary (<operator> <expression>)
-	BANG = "!"
+	BANG // !
 	// Operators: Binary (<expression> <operator> <expression>)
-	ASSIGN   = "="
-	PLUS     = "+"
-	MINUS    = "-"
-	ASTERISK = "*"
-	FSLASH   = "/"
+	ASSIGN   // =
+	PLUS     // +
+	MINUS    // -
+	ASTERISK // *
+	FSLASH   // /
+	PERCENT  // %
 	// Operators: Comparison
-	LT     = "<"
-	GT     = ">"
-	EQ     = "=="
-	NOT_EQ = "!="
+	LT     // <
+	GT     // >
+	LT_EQ  // <=
+	GT_EQ  // >=
+	EQ     // ==
+	NOT_EQ // !=
+	// Operators: Logical, both short-circuit
+	AND // &&
+	OR  // ||
 
 	// Delimiters
-	COMMA     = ","
-	SEMICOLON = ";"
-	LPAREN    = "("
-	RPAREN    = ")"
-	LBRACE    = "{"
-	RBRACE    = "}"
+	COMMA     // ,
+	SEMICOLON // ;
+	COLON     // :
+	LPAREN    // (
+	RPAREN    // )
+	LBRACE    // {
+	RBRACE    // }
+	LBRACKET  // [
+	RBRACKET  // ]
 
 	// Keywords
-	FUNCTION = "FUNCTION"
-	LET      = "LET"
-	IF       = "IF"
-	ELSE     = "ELSE"
-	TRUE     = "TRUE"
-	FALSE    = "FALSE"
-	RETURN   = "RETURN"
+	FUNCTION
+	LET
+	IF
+	ELSE
+	TRUE
+	FALSE
+	RETURN
+
+	// Number of the built-in types, custom ones are numbered from here
+	numTypes
 )
 
+// Names of the token types, as returned by TokenType.String(...).
+// Operators and delimiters are named after how they are written.
+var typeNames = [numTypes]string{
+	ILLEGAL: "ILLEGAL",
+	EOF:     "EOF",
+	COMMENT: "COMMENT",
+
+	IDENT:  "IDENT",
+	INT:    "INT",
+	FLOAT:  "FLOAT",
+	STRING: "STRING",
+
+	TEMPLATE_HEAD:   "TEMPLATE_HEAD",
+	TEMPLATE_MIDDLE: "TEMPLATE_MIDDLE",
+	TEMPLATE_TAIL:   "TEMPLATE_TAIL",
+
+	BANG:     "!",
+	ASSIGN:   "=",
+	PLUS:     "+",
+	MINUS:    "-",
+	ASTERISK: "*",
+	FSLASH:   "/",
+	PERCENT:  "%",
+	LT:       "<",
+	GT:       ">",
+	LT_EQ:    "<=",
+	GT_EQ:    ">=",
+	EQ:       "==",
+	NOT_EQ:   "!=",
+	AND:      "&&",
+	OR:       "||",
+
+	COMMA:     ",",
+	SEMICOLON: ";",
+	COLON:     ":",
+	LPAREN:    "(",
+	RPAREN:    ")",
+	LBRACE:    "{",
+	RBRACE:    "}",
+	LBRACKET:  "[",
+	RBRACKET:  "]",
+
+	FUNCTION: "FUNCTION",
+	LET:      "LET",
+	IF:       "IF",
+	ELSE:     "ELSE",
+	TRUE:     "TRUE",
+	FALSE:    "FALSE",
+	RETURN:   "RETURN",
+}
+
 var keywords = map[string]TokenType{
 	"fn":     FUNCTION,
 	"let":    LET,
@@ -60,6 +162,17 @@ var keywords = map[string]TokenType{
 	"return": RETURN,
 }
 
+// Keywords returns a copy of the default keyword table, which can be
+// changed without affecting LookupIdent(...).
+func Keywords() map[string]TokenType {
+	table := make(map[string]TokenType, len(keywords))
+	for k, v := range keywords {
+		table[k] = v
+	}
+
+	return table
+}
+
 func LookupIdent(identifier string) TokenType {
 	if typ, ok := keywords[identifier]; ok {
 		return typ
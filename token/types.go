@@ -0,0 +1,87 @@
+package token
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Token types added by lexer dialects, see NewType(...)
+var customTypes struct {
+	sync.RWMutex
+	names  []string
+	byName map[string]TokenType
+}
+
+func (t TokenType) String() string {
+	if t < numTypes {
+		return typeNames[t]
+	}
+
+	customTypes.RLock()
+	defer customTypes.RUnlock()
+
+	if i := int(t - numTypes); i < len(customTypes.names) {
+		return customTypes.names[i]
+	}
+
+	return fmt.Sprintf("TokenType(%d)", uint16(t))
+}
+
+// NewType returns a token type for keywords of custom dialects, e.g.
+// NewType("WHILE"). Asking twice for the same name gives the same type,
+// names of the built-in types give the built-in ones.
+func NewType(name string) TokenType {
+	if t, ok := TypeByName(name); ok {
+		return t
+	}
+
+	customTypes.Lock()
+	defer customTypes.Unlock()
+
+	// Somebody could have added it while we were not holding the lock
+	if t, ok := customTypes.byName[name]; ok {
+		return t
+	}
+
+	if customTypes.byName == nil {
+		customTypes.byName = make(map[string]TokenType)
+	}
+
+	t := numTypes + TokenType(len(customTypes.names))
+	customTypes.names = append(customTypes.names, name)
+	customTypes.byName[name] = t
+
+	return t
+}
+
+// TypeByName is the opposite of TokenType.String(...). It knows about
+// the built-in types and those created with NewType(...).
+func TypeByName(name string) (TokenType, bool) {
+	for t, typeName := range typeNames {
+		if typeName == name {
+			return TokenType(t), true
+		}
+	}
+
+	customTypes.RLock()
+	defer customTypes.RUnlock()
+
+	t, ok := customTypes.byName[name]
+	return t, ok
+}
+
+// MarshalText and UnmarshalText make token types show up by name in
+// encoded formats like JSON.
+func (t TokenType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *TokenType) UnmarshalText(text []byte) error {
+	typ, ok := TypeByName(string(text))
+	if !ok {
+		return fmt.Errorf("unknown token type: %q", text)
+	}
+
+	*t = typ
+	return nil
+}